@@ -0,0 +1,200 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/unicoooorn/pingr/internal/checker"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/redact"
+	"github.com/unicoooorn/pingr/internal/service"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func Register() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check backends once, print the results and exit non-zero if any is unhealthy",
+		Long: "Runs a single round of health checks without starting monitoring " +
+			"and without sending alerts.",
+		// Результат уже напечатан, usage только мешает в скриптах
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          check,
+	}
+	cmd.Flags().StringSlice("backend", nil, "Check only these backends")
+	cmd.Flags().StringSlice("type", nil, "Check only backends of these types")
+	cmd.Flags().StringP("output", "o", outputTable, "Output format: table or json")
+	return cmd
+}
+
+type result struct {
+	Backend   string           `json:"backend"`
+	Type      string           `json:"type"`
+	Status    model.PingStatus `json:"status"`
+	LatencyMs float64          `json:"latency_ms"`
+	Details   string           `json:"details"`
+}
+
+func check(cmd *cobra.Command, _ []string) error {
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return fmt.Errorf("failed to get config path: %w", err)
+	}
+	backends, err := cmd.Flags().GetStringSlice("backend")
+	if err != nil {
+		return fmt.Errorf("failed to get backend flag: %w", err)
+	}
+	types, err := cmd.Flags().GetStringSlice("type")
+	if err != nil {
+		return fmt.Errorf("failed to get type flag: %w", err)
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unknown output format '%s', expected table or json", output)
+	}
+
+	// Секреты алертов, например токен Telegram, для разовой проверки не нужны
+	cfg, warnings, err := config.LoadWith(configPath, config.LoadOptions{AllowUnresolved: true})
+	for _, w := range warnings {
+		if w.File == "" {
+			w.File = configPath
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s: warning: %s\n", w.File, w.Path, w.Message)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	names, err := selectBackends(cfg.Backends, backends, types)
+	if err != nil {
+		return err
+	}
+
+	redactor, err := redact.New(*cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create redactor: %w", err)
+	}
+
+	results := runChecks(cmd.Context(), checker.NewChecker(cfg), cfg.Backends, names)
+	for i := range results {
+		results[i].Details = redactor.Redact(results[i].Details)
+	}
+
+	out := cmd.OutOrStdout()
+	if output == outputJSON {
+		err = writeJSON(out, results)
+	} else {
+		err = writeTable(out, results)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+
+	unhealthy := 0
+	for _, r := range results {
+		if r.Status != model.PingStatusOk {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d backends unhealthy", unhealthy, len(results))
+	}
+	return nil
+}
+
+// selectBackends returns the names of the backends matching both filters, in
+// name order. Names given explicitly must exist.
+func selectBackends(all map[string]config.BackendConfig, backends []string, types []string) ([]string, error) {
+	wanted := make(map[string]bool, len(backends))
+	for _, name := range backends {
+		if _, ok := all[name]; !ok {
+			return nil, fmt.Errorf("unknown backend '%s'", name)
+		}
+		wanted[name] = true
+	}
+	wantedTypes := make(map[string]bool, len(types))
+	for _, t := range types {
+		wantedTypes[strings.ToLower(t)] = true
+	}
+
+	var names []string
+	for name, bc := range all {
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		if len(wantedTypes) > 0 && !wantedTypes[bc.Type] {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no backends match the filters")
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// runChecks checks the backends concurrently. A check that fails to run, e.g.
+// because of a missing field, is reported as unhealthy with the error.
+func runChecks(ctx context.Context, c service.Checker, backends map[string]config.BackendConfig, names []string) []result {
+	results := make([]result, len(names))
+
+	var eg errgroup.Group
+	eg.SetLimit(10)
+	for i, name := range names {
+		eg.Go(func() error {
+			start := time.Now()
+			res, err := c.Check(ctx, name)
+			latency := time.Since(start)
+
+			if err != nil {
+				res = model.CheckResult{Status: model.PingStatusNotOk, Details: err.Error()}
+			}
+			results[i] = result{
+				Backend:   name,
+				Type:      backends[name].Type,
+				Status:    res.Status,
+				LatencyMs: float64(latency.Microseconds()) / 1000,
+				Details:   res.Details,
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return results
+}
+
+func writeJSON(w io.Writer, results []result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeTable(w io.Writer, results []result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BACKEND\tTYPE\tSTATUS\tLATENCY\tDETAILS")
+	for _, r := range results {
+		// Многострочные детали ломают таблицу
+		details := strings.Join(strings.Fields(r.Details), " ")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1fms\t%s\n", r.Backend, r.Type, r.Status, r.LatencyMs, details)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,188 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/service/mocks"
+)
+
+func TestSelectBackends(t *testing.T) {
+	all := map[string]config.BackendConfig{
+		"web":   {Type: config.BackendTypeHTTP},
+		"api":   {Type: config.BackendTypeHTTP},
+		"db":    {Type: config.BackendTypePostgres},
+		"cache": {Type: config.BackendTypeRedis},
+	}
+
+	names, err := selectBackends(all, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "cache", "db", "web"}, names)
+
+	names, err = selectBackends(all, nil, []string{config.BackendTypeHTTP, config.BackendTypeRedis})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "cache", "web"}, names)
+
+	names, err = selectBackends(all, []string{"web", "db"}, []string{config.BackendTypeHTTP})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, names)
+
+	_, err = selectBackends(all, []string{"api", "legacy"}, nil)
+	assert.EqualError(t, err, "unknown backend 'legacy'")
+
+	_, err = selectBackends(all, []string{"db"}, []string{config.BackendTypeHTTP})
+	assert.EqualError(t, err, "no backends match the filters")
+}
+
+func TestRunChecks(t *testing.T) {
+	checker := mocks.NewMockChecker(t)
+	checker.On("Check", mock.Anything, "api").
+		Return(model.CheckResult{Status: model.PingStatusOk, Details: "http status code: 200"}, nil)
+	checker.On("Check", mock.Anything, "db").
+		Return(model.CheckResult{}, errors.New("postgres checker: missing url (DSN)"))
+
+	backends := map[string]config.BackendConfig{
+		"api": {Type: config.BackendTypeHTTP},
+		"db":  {Type: config.BackendTypePostgres},
+	}
+	results := runChecks(context.Background(), checker, backends, []string{"api", "db"})
+
+	require.Len(t, results, 2)
+	assert.Equal(t, "api", results[0].Backend)
+	assert.Equal(t, model.PingStatusOk, results[0].Status)
+	assert.Equal(t, result{
+		Backend: "db",
+		Type:    config.BackendTypePostgres,
+		Status:  model.PingStatusNotOk,
+		Details: "postgres checker: missing url (DSN)",
+	}, withoutLatency(results[1]))
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeTable(&buf, []result{
+		{Backend: "api", Type: "http", Status: model.PingStatusOk, LatencyMs: 12.34, Details: "http status code: 200"},
+		{Backend: "db", Type: "postgres", Status: model.PingStatusNotOk, Details: "dial tcp:\n  connection refused\n"},
+	}))
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"BACKEND", "TYPE", "STATUS", "LATENCY", "DETAILS"}, strings.Fields(lines[0]))
+	assert.True(t, strings.HasPrefix(lines[1], "api "))
+	assert.Contains(t, lines[1], "12.3ms")
+	assert.True(t, strings.HasSuffix(lines[2], "dial tcp: connection refused"))
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, []result{
+		{Backend: "db", Type: "postgres", Status: model.PingStatusNotOk, LatencyMs: 1.5, Details: "connection refused"},
+	}))
+
+	var decoded []map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, []map[string]any{{
+		"backend":    "db",
+		"type":       "postgres",
+		"status":     "not_ok",
+		"latency_ms": 1.5,
+		"details":    "connection refused",
+	}}, decoded)
+}
+
+func TestCheck_ExitCode(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+backends:
+  api:
+    type: http
+    url: `+healthy.URL+`
+  web:
+    type: http
+    url: `+broken.URL+`
+`), 0644))
+
+	out, _, err := execute(t, "-c", configPath, "--backend", "api")
+	require.NoError(t, err)
+	assert.Contains(t, out, "api")
+
+	out, _, err = execute(t, "-c", configPath, "-o", "json")
+	assert.EqualError(t, err, "1 of 2 backends unhealthy")
+	var results []result
+	require.NoError(t, json.Unmarshal([]byte(out), &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, model.PingStatusOk, results[0].Status)
+	assert.Equal(t, model.PingStatusNotOk, results[1].Status)
+
+	_, _, err = execute(t, "-c", configPath, "-o", "yaml")
+	assert.EqualError(t, err, "unknown output format 'yaml', expected table or json")
+}
+
+// Неразрешённые секреты алертов не мешают разовой проверке
+func TestCheck_UnsetAlertSecrets(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	t.Setenv("TG_TOKEN", "")
+	os.Unsetenv("TG_TOKEN")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+backends:
+  api:
+    type: http
+    url: `+healthy.URL+`
+telegram:
+  token: ${TG_TOKEN}
+  chat_id: "1"
+`), 0644))
+
+	out, stderr, err := execute(t, "-c", configPath, "-o", "json")
+	require.NoError(t, err)
+	assert.Equal(t, configPath+": telegram.token: warning: environment variable TG_TOKEN is not set\n", stderr)
+	var results []result
+	require.NoError(t, json.Unmarshal([]byte(out), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, model.PingStatusOk, results[0].Status)
+}
+
+// execute runs the check command as the root command would, with the
+// persistent config flag.
+func execute(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	root := &cobra.Command{Use: "pingr"}
+	root.PersistentFlags().StringP("config", "c", "", "")
+	root.AddCommand(Register())
+
+	var stdout, stderr bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetArgs(append([]string{"check"}, args...))
+	err := root.ExecuteContext(context.Background())
+	return stdout.String(), stderr.String(), err
+}
+
+func withoutLatency(r result) result {
+	r.LatencyMs = 0
+	return r
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/unicoooorn/pingr/cmd/check"
 	"github.com/unicoooorn/pingr/cmd/run"
+	"github.com/unicoooorn/pingr/cmd/schema"
+	"github.com/unicoooorn/pingr/cmd/validate"
 )
 
 func NewRootCmd() *cobra.Command {
@@ -13,6 +16,9 @@ func NewRootCmd() *cobra.Command {
 	}
 
 	rootCmd.AddCommand(run.Register())
+	rootCmd.AddCommand(schema.Register())
+	rootCmd.AddCommand(validate.Register())
+	rootCmd.AddCommand(check.Register())
 
 	rootCmd.PersistentFlags().StringP("config", "c", "config/config.yaml", "Specify a config file")
 
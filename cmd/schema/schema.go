@@ -0,0 +1,25 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
+func Register() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config file",
+		RunE:  schema,
+	}
+}
+
+func schema(cmd *cobra.Command, _ []string) error {
+	data, err := config.JSONSchema()
+	if err != nil {
+		return fmt.Errorf("failed to build config schema: %w", err)
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
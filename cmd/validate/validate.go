@@ -0,0 +1,141 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/unicoooorn/pingr/internal/app"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/log_extractor"
+	metrics_extractor "github.com/unicoooorn/pingr/internal/metrics_extactor"
+)
+
+const pingTimeout = 10 * time.Second
+
+func Register() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file and print every problem",
+		// Проблемы уже напечатаны построчно, usage и повтор ошибки только мешают в CI
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          validate,
+	}
+	cmd.Flags().Bool("check-datasources", false, "Also check that Prometheus datasources and Loki respond")
+	// В CI секретов обычно нет, поэтому неразрешённые ${VAR} по умолчанию только предупреждения
+	cmd.Flags().Bool("strict", false, "Treat unset env variables and unreadable files in ${...} references as problems")
+	return cmd
+}
+
+func validate(cmd *cobra.Command, _ []string) error {
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return fmt.Errorf("failed to get config path: %w", err)
+	}
+	checkDatasources, err := cmd.Flags().GetBool("check-datasources")
+	if err != nil {
+		return fmt.Errorf("failed to get check-datasources flag: %w", err)
+	}
+	strict, err := cmd.Flags().GetBool("strict")
+	if err != nil {
+		return fmt.Errorf("failed to get strict flag: %w", err)
+	}
+	out := cmd.OutOrStdout()
+
+	cfg, warnings, err := config.LoadWith(configPath, config.LoadOptions{AllowUnresolved: !strict})
+	for _, w := range warnings {
+		fmt.Fprintln(out, located(w, configPath, "warning: "))
+	}
+	if err != nil {
+		var verr *config.ValidationError
+		if !errors.As(err, &verr) {
+			fmt.Fprintf(out, "%s: %v\n", configPath, err)
+			return fmt.Errorf("config %s is invalid", configPath)
+		}
+		for _, p := range verr.Problems {
+			fmt.Fprintln(out, located(p, configPath, ""))
+		}
+		return fmt.Errorf("config %s is invalid: %d problem(s)", configPath, len(verr.Problems))
+	}
+
+	var problems []string
+	// Ошибки, которые иначе всплыли бы только при старте run, например неверный шаблон промпта
+	if err := app.Validate(*cfg); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", configPath, err))
+	}
+	if checkDatasources {
+		for _, p := range pingDatasources(cmd.Context(), *cfg) {
+			problems = append(problems, fmt.Sprintf("%s: %s", configPath, p))
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Fprintln(out, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("config %s is invalid: %d problem(s)", configPath, len(problems))
+	}
+
+	fmt.Fprintf(out, "%s: ok, %d backends", configPath, len(cfg.Backends))
+	if len(warnings) > 0 {
+		fmt.Fprintf(out, ", %d warning(s)", len(warnings))
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+// located prints a problem as file: path: message, the main file by its path.
+func located(p config.Problem, configPath string, prefix string) string {
+	if p.File == "" {
+		p.File = configPath
+	}
+	p.Message = prefix + p.Message
+	return p.String()
+}
+
+// pingDatasources returns a problem for every datasource that doesn't respond,
+// located at its config path.
+func pingDatasources(ctx context.Context, cfg config.Config) []string {
+	var problems []string
+	ping := func(path string, pinger interface{ Ping(context.Context) error }) {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		if err := pinger.Ping(ctx); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: unreachable: %v", path, err))
+		}
+	}
+
+	datasources := cfg.AllDatasources()
+	names := make([]string, 0, len(datasources))
+	for name := range datasources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := "datasources." + name
+		if _, ok := cfg.Datasources[name]; !ok && name == config.DefaultDatasource {
+			path = "prometheus"
+		}
+		extractor, err := metrics_extractor.NewPrometheusMetricsExtractor(datasources[name])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		ping(path+".url", extractor)
+	}
+
+	if cfg.Loki.URL != "" {
+		extractor, err := log_extractor.NewLokiLogExtractor(cfg.Loki)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("loki: %v", err))
+		} else {
+			ping("loki.url", extractor)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validConfig = `
+prometheus:
+  url: http://prometheus:9090
+telegram:
+  token: ${PINGR_VALIDATE_TEST_TOKEN}
+backends:
+  api:
+    type: http
+    url: http://api/health
+`
+
+func TestValidate_OK(t *testing.T) {
+	t.Setenv("PINGR_VALIDATE_TEST_TOKEN", "123:abc")
+	configPath := writeConfig(t, validConfig)
+
+	out, err := execute(t, "-c", configPath)
+	require.NoError(t, err)
+	assert.Equal(t, configPath+": ok, 1 backends\n", out)
+}
+
+func TestValidate_Problems(t *testing.T) {
+	configPath := writeConfig(t, `
+prometheus:
+  url: http://prometheus:9090
+backends:
+  api:
+    type: http
+  db:
+    type: postgres
+    url: postgres://db/app
+    deps: [missing]
+`)
+
+	out, err := execute(t, "-c", configPath)
+	assert.EqualError(t, err, "config "+configPath+" is invalid: 2 problem(s)")
+	assert.Equal(t, configPath+": backends.api.url: required for type http\n"+
+		configPath+": backends.db.deps[0]: unknown dependency 'missing'\n", out)
+}
+
+// Без секретов конфиг проверяется с предупреждениями, --strict делает их ошибками
+func TestValidate_UnresolvedReferences(t *testing.T) {
+	configPath := writeConfig(t, validConfig)
+	warning := configPath + ": telegram.token: warning: environment variable PINGR_VALIDATE_TEST_TOKEN is not set\n"
+
+	out, err := execute(t, "-c", configPath)
+	require.NoError(t, err)
+	assert.Equal(t, warning+configPath+": ok, 1 backends, 1 warning(s)\n", out)
+
+	out, err = execute(t, "-c", configPath, "--strict")
+	assert.EqualError(t, err, "config "+configPath+" is invalid: 1 problem(s)")
+	assert.Equal(t, configPath+": telegram.token: environment variable PINGR_VALIDATE_TEST_TOKEN is not set\n", out)
+}
+
+// Сломанная секция llm не прячется за шаблонным алертом
+func TestValidate_BrokenLLM(t *testing.T) {
+	t.Setenv("PINGR_VALIDATE_TEST_TOKEN", "123:abc")
+	configPath := writeConfig(t, validConfig+`
+llm:
+  base_url: http://ollama:11434/v1
+`)
+
+	out, err := execute(t, "-c", configPath)
+	assert.EqualError(t, err, "config "+configPath+" is invalid: 1 problem(s)")
+	assert.Equal(t, configPath+": unable to generate alerts: llm model not configured\n", out)
+}
+
+func TestValidate_UnreadableFile(t *testing.T) {
+	out, err := execute(t, "-c", filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+	assert.Contains(t, out, "missing.yaml: ")
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+	return configPath
+}
+
+// execute runs the validate command as the root command would, with the
+// persistent config flag.
+func execute(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	root := &cobra.Command{Use: "pingr"}
+	root.PersistentFlags().StringP("config", "c", "", "")
+	root.AddCommand(Register())
+
+	var buf bytes.Buffer
+	root.SetOut(&buf)
+	root.SetErr(&buf)
+	root.SetArgs(append([]string{"validate"}, args...))
+	err := root.ExecuteContext(context.Background())
+	return buf.String(), err
+}
@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+
+	openai "github.com/openai/openai-go/v3"
+)
+
+const (
+	// Сколько раз просим модель исправить невалидный ответ, включая первый запрос
+	maxAnalysisAttempts = 3
+
+	analysisSchemaName = "rca_analysis"
+)
+
+// analysisSchema describes the expected RCA response. Strict mode requires
+// every property to be listed in required, so an unknown root cause is "".
+func analysisSchema(backends []string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"root_cause_backend": map[string]any{
+				"type":        "string",
+				"enum":        append(append([]string(nil), backends...), ""),
+				"description": "Name of the backend that most likely caused the incident, empty if unknown",
+			},
+			"confidence": map[string]any{
+				"type":        "number",
+				"description": "Confidence in the root cause from 0 to 1",
+			},
+			"summary": map[string]any{
+				"type":        "string",
+				"description": "Short explanation of the root cause",
+			},
+			"remediation_steps": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+			},
+		},
+		"required":             []string{"root_cause_backend", "confidence", "summary", "remediation_steps"},
+		"additionalProperties": false,
+	}
+}
+
+// responseFormat builds the response_format request parameter; nil means free text.
+func responseFormat(format string, backends []string) *openai.ChatCompletionNewParamsResponseFormatUnion {
+	switch format {
+	case config.LLMResponseFormatText:
+		return nil
+	case config.LLMResponseFormatJSONObject:
+		return &openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &openai.ResponseFormatJSONObjectParam{},
+		}
+	default:
+		return &openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   analysisSchemaName,
+					Schema: analysisSchema(backends),
+					Strict: openai.Bool(true),
+				},
+			},
+		}
+	}
+}
+
+// parseAnalysis decodes and validates the model response against the known backends.
+func parseAnalysis(content string, subsystemInfoByName map[string]model.SubsystemInfo) (*model.RCAAnalysis, error) {
+	content = stripCodeFence(content)
+
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.DisallowUnknownFields()
+
+	var analysis model.RCAAnalysis
+	if err := dec.Decode(&analysis); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+
+	if analysis.RootCauseBackend != "" {
+		if _, ok := subsystemInfoByName[analysis.RootCauseBackend]; !ok {
+			return nil, fmt.Errorf("unknown root_cause_backend %q", analysis.RootCauseBackend)
+		}
+	}
+	if analysis.Confidence < 0 || analysis.Confidence > 1 {
+		return nil, fmt.Errorf("confidence %g is out of range [0, 1]", analysis.Confidence)
+	}
+	if strings.TrimSpace(analysis.Summary) == "" {
+		return nil, fmt.Errorf("summary is empty")
+	}
+	if len(analysis.RemediationSteps) == 0 {
+		return nil, fmt.Errorf("remediation_steps is empty")
+	}
+	for i, step := range analysis.RemediationSteps {
+		if strings.TrimSpace(step) == "" {
+			return nil, fmt.Errorf("remediation step %d is empty", i+1)
+		}
+	}
+
+	return &analysis, nil
+}
+
+// stripCodeFence removes a markdown code fence that some models add around JSON.
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if nl := strings.IndexByte(content, '\n'); nl >= 0 {
+		content = content[nl+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+func correctionMessage(err error) string {
+	return fmt.Sprintf(
+		"Your previous response was invalid: %v. Reply again with only a JSON object "+
+			"with fields root_cause_backend, confidence, summary and remediation_steps.",
+		err,
+	)
+}
+
+func renderAnalysis(analysis *model.RCAAnalysis) string {
+	var sb strings.Builder
+	if analysis.RootCauseBackend != "" {
+		sb.WriteString(fmt.Sprintf("Root cause: %s (confidence %.0f%%)\n", analysis.RootCauseBackend, analysis.Confidence*100))
+	} else {
+		sb.WriteString("Root cause: unknown\n")
+	}
+	sb.WriteString(strings.TrimSpace(analysis.Summary))
+	sb.WriteString("\n\nRemediation:\n")
+	for i, step := range analysis.RemediationSteps {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, strings.TrimSpace(step)))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func backendNames(subsystemInfoByName map[string]model.SubsystemInfo) []string {
+	names := make([]string, 0, len(subsystemInfoByName))
+	for name := range subsystemInfoByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/service"
+)
+
+// analysisCache keeps LLM analyses by incident fingerprint for a TTL, so a
+// repeating incident doesn't cost a new request every cycle.
+// A nil cache or zero TTL disables caching.
+type analysisCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]analysisCacheEntry
+}
+
+type analysisCacheEntry struct {
+	msg     model.AlertMessage
+	expires time.Time
+}
+
+func newAnalysisCache(ttl time.Duration) *analysisCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &analysisCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]analysisCacheEntry),
+	}
+}
+
+// KeepCache hands the LLM analyses cached by prev over to next when both use
+// the same llm settings, so a config reload doesn't repeat requests for an
+// ongoing incident.
+func KeepCache(prev, next service.AlertGenerator) {
+	p, n := llmOf(prev), llmOf(next)
+	if p == nil || n == nil || p.cache == nil || n.cache == nil {
+		return
+	}
+	if !reflect.DeepEqual(p.config.LLM, n.config.LLM) {
+		return
+	}
+	n.cache = p.cache
+}
+
+func llmOf(g service.AlertGenerator) *llmApi {
+	switch g := g.(type) {
+	case *llmApi:
+		return g
+	case *fallbackGenerator:
+		return llmOf(g.primary)
+	default:
+		return nil
+	}
+}
+
+func (c *analysisCache) get(fingerprint string) (model.AlertMessage, bool) {
+	if c == nil {
+		return model.AlertMessage{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[fingerprint]
+	if !ok {
+		return model.AlertMessage{}, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, fingerprint)
+		return model.AlertMessage{}, false
+	}
+	return entry.msg, true
+}
+
+func (c *analysisCache) put(fingerprint string, msg model.AlertMessage) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[fingerprint] = analysisCacheEntry{
+		msg:     msg,
+		expires: now.Add(c.ttl),
+	}
+}
@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/service"
+)
+
+var _ service.AlertGenerator = &fallbackGenerator{}
+
+// fallbackGenerator uses the fallback generator whenever the primary one fails.
+type fallbackGenerator struct {
+	primary  service.AlertGenerator
+	fallback service.AlertGenerator
+}
+
+func NewFallbackGenerator(primary service.AlertGenerator, fallback service.AlertGenerator) *fallbackGenerator {
+	return &fallbackGenerator{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (g *fallbackGenerator) GenerateAlertMessage(
+	ctx context.Context,
+	subsystemInfoByName map[string]model.SubsystemInfo,
+) (model.AlertMessage, error) {
+	msg, err := g.primary.GenerateAlertMessage(ctx, subsystemInfoByName)
+	if err == nil {
+		return msg, nil
+	}
+
+	slog.Warn("primary alert generator failed, using fallback", "error", err)
+
+	msg, fallbackErr := g.fallback.GenerateAlertMessage(ctx, subsystemInfoByName)
+	if fallbackErr != nil {
+		return model.AlertMessage{}, errors.Join(err, fallbackErr)
+	}
+	return msg, nil
+}
+
+// NewAlertGenerator builds the generator selected by config.Alert.Generator.
+func NewAlertGenerator(cfg *config.Config) (service.AlertGenerator, error) {
+	switch cfg.Alert.Generator {
+	case config.AlertGeneratorTemplate:
+		return NewTemplateGenerator(cfg)
+	case config.AlertGeneratorLLM:
+		return NewLLMApi(cfg)
+	case "", config.AlertGeneratorLLMWithFallback:
+		tmplGen, err := NewTemplateGenerator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.ValueOf(cfg.LLM).IsZero() {
+			slog.Info("llm section is not configured, using template alerts only")
+			return tmplGen, nil
+		}
+		// Шаблон подстраховывает только сбои LLM во время работы: ошибку
+		// в секции llm должны показать validate и старт, а не тихий откат
+		llmGen, err := NewLLMApi(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewFallbackGenerator(llmGen, tmplGen), nil
+	default:
+		return nil, fmt.Errorf("unknown alert generator %q", cfg.Alert.Generator)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"html/template"
+	"log/slog"
 	"os"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/incident"
 	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/redact"
 	"github.com/unicoooorn/pingr/internal/service"
 	"go.yaml.in/yaml/v3"
 
@@ -27,23 +32,70 @@ Your task is to analyze the provided data about service health, dependencies, an
 ` + "`" + "`" + "`" + `yaml
 {{.ServiceDeps}}
 ` + "`" + "`" + "`" + `
+{{- if .ServicesWithContext}}
+## 1.1. Service Context
+Descriptions, owners and known failure modes provided by the service owners.
+{{range .ServicesWithContext}}
+### {{.Name}}
+{{- if .Description}}
+- Description: {{.Description}}{{end}}
+{{- if .Owner}}
+- Owner: {{.Owner}}{{end}}
+{{- if .RunbookURL}}
+- Runbook: {{.RunbookURL}}{{end}}
+{{- if .Notes}}
+- Notes: {{.Notes}}{{end}}
+{{end}}{{end}}
 ## 2. Service Statuses
 {{.ServiceStatusesTable}}
+{{- if .ServiceTimeline}}
+## 2.1. Status Timeline
+Recent status transitions of the services, oldest first.
+` + "`" + "`" + "`" + `
+{{.ServiceTimeline}}` + "`" + "`" + "`" + `
+{{- if .FailureOrder}}
+Order in which the currently failing services went red:
+{{.FailureOrder}}{{end}}{{end}}
 ## 3. Related Metrics
 The following metrics are pulled from Prometheus for each affected service.
 ` + "`" + "`" + "`" + `yaml
 {{.ServiceMetrics}}
 ` + "`" + "`" + "`" + `
+{{if .ServiceAnomalies}}
+Metrics that deviate from their usual baseline:
+{{.ServiceAnomalies}}{{end}}{{if .ServiceLogs}}
+## 3.1. Recent Error Logs
+The latest log lines of the failing services, newest first.
+` + "`" + "`" + "`" + `
+{{.ServiceLogs}}` + "`" + "`" + "`" + `
+{{end}}
 ## 4. Task
 Using the data above, perform a root cause analysis.
+{{- if .Structured}}
+Respond with only a JSON object with the following fields:
+	- root_cause_backend - name of the service from the dependency graph that most probably triggered the incident, or an empty string if it can't be determined.
+	- confidence - your confidence in the root cause, a number from 0 to 1.
+	- summary - the most probable service or metric degradation that triggered the incident.
+	- remediation_steps - list of actions that should be taken to resolve the root issue or prevent recurrence.
+{{- else}}
 Your response should consist of exactly the following:
 	- Root Cause Summary - the most probable service or metric degradation that triggered the incident.
 	- Suggested Remediation – what actions should be taken to resolve the root issue or prevent recurrence.
+{{- end}}
 ## 5. Notes
 - Answer concisely. No more than a few sentences for each point.
 - If several services failed simultaneously, prioritize identifying the one they depend on.
 - If all dependencies are healthy, analyze metrics for performance degradation or latency spikes.
-- Use the dependency graph to reason causally about failure propagation.`
+- Treat metrics deviating from their baseline as stronger evidence than absolute values.
+- Use the dependency graph to reason causally about failure propagation.
+- A service that went red before its dependents is the more likely root cause.
+- Prefer remediation from the service context and runbooks over generic advice.`
+)
+
+const (
+	// Сколько строк логов на бэкенд попадает в промпт и в сообщение
+	promptLogLines  = 20
+	messageLogLines = 3
 )
 
 var _ service.AlertGenerator = &llmApi{}
@@ -52,47 +104,267 @@ type PromptData struct {
 	ServiceDeps          string
 	ServiceStatusesTable string
 	ServiceMetrics       string
+	ServiceAnomalies     string
+	ServiceLogs          string
+	// ServiceTimeline lists status transitions of all services, FailureOrder
+	// the failing ones in the order they went red
+	ServiceTimeline string
+	FailureOrder    string
+	// Structured is set when the model must answer with a JSON analysis
+	Structured bool
+	// Services holds every backend in name order, ServicesWithContext only
+	// those with a description, owner, runbook or notes
+	Services            []PromptService
+	ServicesWithContext []PromptService
+}
+
+// PromptService is the per-backend data available to prompt templates.
+type PromptService struct {
+	Name        string
+	Type        string
+	Status      model.PingStatus
+	Details     string
+	Deps        []string
+	Description string
+	Owner       string
+	RunbookURL  string
+	Notes       string
+	// Timeline holds the service's status transitions, oldest first;
+	// FailingSince is zero when the service is healthy
+	Timeline     []model.StatusChange
+	FailingSince time.Time
+}
+
+func (s PromptService) HasContext() bool {
+	return s.Description != "" || s.Owner != "" || s.RunbookURL != "" || s.Notes != ""
+}
+
+type promptBackend struct {
+	Type   string            `yaml:"type,omitempty"`
+	Deps   []string          `yaml:"deps,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
 }
 
 type llmApi struct {
-	client *openai.Client
-	model  string
-	config *config.Config
+	client      *openai.Client
+	model       string
+	temperature float64
+	maxTokens   int64
+	format      string
+	timeout     time.Duration
+	cache       *analysisCache
+	prompt      *template.Template
+	config      *config.Config
+	redactor    *redact.Redactor
 }
 
-func NewLLMApi(config *config.Config) *llmApi {
-	client := openai.NewClient(
-		option.WithAPIKey(os.Getenv("YANDEX_CLOUD_API_KEY")),
-		option.WithBaseURL(yaBaseUrl),
-		option.WithHeader("OpenAI-Project", os.Getenv("YANDEX_CLOUD_FOLDER")),
-	)
+const (
+	defaultTemperature = 0.3
+	// Медленная модель может задержать алерт, но не остановить цикл проверок
+	defaultTimeout = time.Minute
+)
+
+// NewLLMApi creates a generator for any OpenAI-compatible endpoint described
+// by the llm config section. Without base_url the Yandex Cloud defaults are used.
+func NewLLMApi(config *config.Config) (*llmApi, error) {
+	llmCfg := config.LLM
+	if llmCfg.BaseURL == "" {
+		var err error
+		if llmCfg, err = yandexDefaults(llmCfg); err != nil {
+			return nil, err
+		}
+	}
+	if llmCfg.Model == "" {
+		return nil, fmt.Errorf("llm model not configured")
+	}
+
+	prompt, err := loadPromptTemplate(llmCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor, err := redact.New(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Self-hosted endpoints such as Ollama often need no key
+	opts := []option.RequestOption{
+		option.WithAPIKey(llmCfg.APIKey),
+		option.WithBaseURL(llmCfg.BaseURL),
+	}
+	for k, v := range llmCfg.Headers {
+		opts = append(opts, option.WithHeader(k, v))
+	}
+	if llmCfg.MaxRetries != nil {
+		opts = append(opts, option.WithMaxRetries(*llmCfg.MaxRetries))
+	}
+	client := openai.NewClient(opts...)
+
+	temperature := defaultTemperature
+	if llmCfg.Temperature != nil {
+		temperature = *llmCfg.Temperature
+	}
+	timeout := llmCfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
 
 	return &llmApi{
-		client: &client,
-		model:  "gpt://" + os.Getenv("YANDEX_CLOUD_FOLDER") + "/" + yaModel + "/latest",
-		config: config,
+		client:      &client,
+		model:       llmCfg.Model,
+		temperature: temperature,
+		maxTokens:   llmCfg.MaxTokens,
+		format:      llmCfg.ResponseFormat,
+		timeout:     timeout,
+		cache:       newAnalysisCache(llmCfg.CacheTTL),
+		prompt:      prompt,
+		config:      config,
+		redactor:    redactor,
+	}, nil
+}
+
+// loadPromptTemplate parses the prompt from llm.prompt_template_file,
+// llm.prompt_template or the built-in default, in that order.
+func loadPromptTemplate(cfg config.LLMConfig) (*template.Template, error) {
+	text := cfg.PromptTemplate
+	if cfg.PromptTemplateFile != "" {
+		b, err := os.ReadFile(cfg.PromptTemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("read prompt template: %w", err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		text = propmptTemplate
+	}
+
+	tmpl, err := template.New("prompt").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// yandexDefaults keeps the historical Yandex Cloud setup working for configs
+// without an explicit llm.base_url. The key and the folder come from the
+// config like any other secret, e.g. api_key: ${YANDEX_CLOUD_API_KEY}.
+func yandexDefaults(cfg config.LLMConfig) (config.LLMConfig, error) {
+	folder := cfg.Folder
+	if folder == "" {
+		return cfg, fmt.Errorf("llm.folder is required for Yandex Cloud without llm.base_url")
+	}
+
+	cfg.BaseURL = yaBaseUrl
+	if cfg.Model == "" {
+		cfg.Model = "gpt://" + folder + "/" + yaModel + "/latest"
 	}
+	headers := map[string]string{"OpenAI-Project": folder}
+	for k, v := range cfg.Headers {
+		if strings.EqualFold(k, "OpenAI-Project") {
+			delete(headers, "OpenAI-Project")
+		}
+		headers[k] = v
+	}
+	cfg.Headers = headers
+	return cfg, nil
 }
 
 func (l *llmApi) GenerateAlertMessage(
 	ctx context.Context,
 	subsystemInfoByName map[string]model.SubsystemInfo,
-) (string, error) {
-	prompt, err := buildPrompt(l.config, subsystemInfoByName)
+) (model.AlertMessage, error) {
+	fingerprint := incident.Fingerprint(subsystemInfoByName)
+	msg, ok := l.cache.get(fingerprint)
+	if ok {
+		slog.Info("reusing cached llm analysis", "fingerprint", fingerprint)
+		llmCacheHits.WithLabelValues(l.model).Inc()
+	} else {
+		var err error
+		msg, err = l.analyze(ctx, subsystemInfoByName)
+		if err != nil {
+			return model.AlertMessage{}, err
+		}
+		l.cache.put(fingerprint, msg)
+	}
+
+	// Логи свежие даже для закэшированного анализа
+	if excerpt := formatLogs(subsystemInfoByName, messageLogLines); excerpt != "" {
+		msg.Text += "\n\nRecent logs:\n" + l.redactor.Redact(excerpt)
+	}
+
+	return msg, nil
+}
+
+func (l *llmApi) analyze(
+	ctx context.Context,
+	subsystemInfoByName map[string]model.SubsystemInfo,
+) (model.AlertMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	structured := l.format != config.LLMResponseFormatText
+	prompt, err := buildPrompt(l.prompt, l.config, subsystemInfoByName, structured)
 	if err != nil {
-		return "", fmt.Errorf("build prompt: %w", err)
+		return model.AlertMessage{}, fmt.Errorf("build prompt: %w", err)
 	}
+	prompt = l.redactor.Redact(prompt)
 
-	resp, err := l.client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+	params := openai.ChatCompletionNewParams{
 		Model: l.model,
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			openai.UserMessage(prompt),
 		},
-		Temperature: openai.Float(0.3),
-	})
+		Temperature: openai.Float(l.temperature),
+	}
+	if l.maxTokens > 0 {
+		params.MaxTokens = openai.Int(l.maxTokens)
+	}
+	if format := responseFormat(l.format, backendNames(subsystemInfoByName)); format != nil {
+		params.ResponseFormat = *format
+	}
+
+	var msg model.AlertMessage
+	for attempt := 1; ; attempt++ {
+		content, err := l.complete(ctx, params)
+		if err != nil {
+			return model.AlertMessage{}, err
+		}
+
+		if !structured {
+			msg.Text = content
+			break
+		}
+
+		analysis, err := parseAnalysis(content, subsystemInfoByName)
+		if err == nil {
+			msg = model.AlertMessage{Text: renderAnalysis(analysis), Analysis: analysis}
+			break
+		}
+		if attempt == maxAnalysisAttempts {
+			return model.AlertMessage{}, fmt.Errorf("invalid analysis after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("llm returned invalid analysis, retrying", "attempt", attempt, "error", err)
+		params.Messages = append(params.Messages,
+			openai.AssistantMessage(content),
+			openai.UserMessage(correctionMessage(err)),
+		)
+	}
+
+	msg.Text = l.redactor.Redact(msg.Text)
+	return msg, nil
+}
+
+func (l *llmApi) complete(ctx context.Context, params openai.ChatCompletionNewParams) (string, error) {
+	started := time.Now()
+	resp, err := l.client.Chat.Completions.New(ctx, params)
+	observeCompletion(l.model, started, resp, err)
 
 	if err != nil {
-		return "", fmt.Errorf("failed to call LLM API: %s", err)
+		return "", fmt.Errorf("failed to call LLM API: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
@@ -102,9 +374,18 @@ func (l *llmApi) GenerateAlertMessage(
 	return resp.Choices[0].Message.Content, nil
 }
 
-func buildPrompt(config *config.Config, subsystemInfoByName map[string]model.SubsystemInfo) (string, error) {
-	// Convert the whole config text to dependencies string
-	cfgYamlBytes, err := yaml.Marshal(config)
+func buildPrompt(tmpl *template.Template, config *config.Config, subsystemInfoByName map[string]model.SubsystemInfo, structured bool) (string, error) {
+	// Only the dependency graph and non-sensitive metadata: URLs, headers and
+	// credentials from the config never reach the model
+	graph := make(map[string]promptBackend, len(config.Backends))
+	for name, bc := range config.Backends {
+		graph[name] = promptBackend{
+			Type:   bc.Type,
+			Deps:   bc.Deps,
+			Labels: bc.Labels,
+		}
+	}
+	cfgYamlBytes, err := yaml.Marshal(map[string]any{"backends": graph})
 	if err != nil {
 		return "", fmt.Errorf("error marshalling to YAML: %v", err)
 	}
@@ -112,17 +393,30 @@ func buildPrompt(config *config.Config, subsystemInfoByName map[string]model.Sub
 
 	// Build statuses table
 	var sb strings.Builder
-	for name, data := range subsystemInfoByName {
-		sb.WriteString(fmt.Sprintf("|%-10s| %6s |\n", name, data.Check.Status))
+	for _, name := range backendNames(subsystemInfoByName) {
+		sb.WriteString(fmt.Sprintf("|%-10s| %6s |\n", name, subsystemInfoByName[name].Check.Status))
 	}
 	statusTable := sb.String()
 
 	// Build metrics YAML
 	metricsMap := map[string]map[string]any{}
+	var anomalies strings.Builder
 	for name, data := range subsystemInfoByName {
 		metricsMap[name] = make(map[string]any)
 		for _, metric := range data.Metric.Metrics {
-			metricsMap[name][metric.Name] = metric.Value
+			if metric.Baseline == nil {
+				metricsMap[name][metric.Name] = metric.Value
+				continue
+			}
+			metricsMap[name][metric.Name] = map[string]any{
+				"value":     metric.Value,
+				"baseline":  metric.Baseline.Mean,
+				"deviation": metric.Baseline.String(),
+				"anomalous": metric.Baseline.Anomalous,
+			}
+			if metric.Baseline.Anomalous {
+				anomalies.WriteString(fmt.Sprintf("- %s: %s is %g, %s\n", name, metric.Name, metric.Value, metric.Baseline))
+			}
 		}
 	}
 	metricsYAMLBytes, err := yaml.Marshal(metricsMap)
@@ -135,11 +429,32 @@ func buildPrompt(config *config.Config, subsystemInfoByName map[string]model.Sub
 		ServiceDeps:          deps,
 		ServiceStatusesTable: statusTable,
 		ServiceMetrics:       metricsYAML,
+		ServiceAnomalies:     anomalies.String(),
+		ServiceLogs:          formatLogs(subsystemInfoByName, promptLogLines),
+		ServiceTimeline:      formatTimeline(subsystemInfoByName),
+		FailureOrder:         formatFailureOrder(subsystemInfoByName),
+		Structured:           structured,
 	}
+	for _, name := range backendNames(subsystemInfoByName) {
+		bc := config.Backends[name]
+		svc := PromptService{
+			Name:        name,
+			Type:        bc.Type,
+			Status:      subsystemInfoByName[name].Check.Status,
+			Details:     subsystemInfoByName[name].Check.Details,
+			Deps:        bc.Deps,
+			Description: bc.Description,
+			Owner:       bc.Owner,
+			RunbookURL:  bc.RunbookURL,
+			Notes:       bc.Notes,
 
-	tmpl, err := template.New("prompt").Parse(propmptTemplate)
-	if err != nil {
-		return "", fmt.Errorf("parse template: %w", err)
+			Timeline:     subsystemInfoByName[name].Timeline,
+			FailingSince: failingSince(subsystemInfoByName[name]),
+		}
+		data.Services = append(data.Services, svc)
+		if svc.HasContext() {
+			data.ServicesWithContext = append(data.ServicesWithContext, svc)
+		}
 	}
 
 	var buf bytes.Buffer
@@ -149,3 +464,26 @@ func buildPrompt(config *config.Config, subsystemInfoByName map[string]model.Sub
 
 	return buf.String(), nil
 }
+
+// formatLogs renders up to maxLines log lines per backend, backends in name order.
+func formatLogs(subsystemInfoByName map[string]model.SubsystemInfo, maxLines int) string {
+	names := make([]string, 0, len(subsystemInfoByName))
+	for name, data := range subsystemInfoByName {
+		if len(data.Logs.Lines) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("[%s]\n", name))
+		for i, line := range subsystemInfoByName[name].Logs.Lines {
+			if i == maxLines {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%s %s\n", line.Timestamp.UTC().Format(time.RFC3339), line.Line))
+		}
+	}
+	return sb.String()
+}
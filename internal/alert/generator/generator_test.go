@@ -0,0 +1,652 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+// newChatServer emulates an OpenAI-compatible endpoint and records the last request.
+func newChatServer(t *testing.T, reply string, received *map[string]any, headers *http.Header) *httptest.Server {
+	return newScriptedChatServer(t, []string{reply}, func(body map[string]any, h http.Header) {
+		if received != nil {
+			*received = body
+		}
+		if headers != nil {
+			*headers = h
+		}
+	})
+}
+
+// newScriptedChatServer answers with replies in order, repeating the last one.
+func newScriptedChatServer(t *testing.T, replies []string, onRequest func(body map[string]any, headers http.Header)) *httptest.Server {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var body map[string]any
+		raw, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(raw, &body))
+		if onRequest != nil {
+			onRequest(body, r.Header.Clone())
+		}
+
+		reply := replies[min(calls, len(replies)-1)]
+		calls++
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "cmpl-1",
+			"object":  "chat.completion",
+			"created": 1700000000,
+			"model":   "llama3",
+			"choices": []map[string]any{
+				{
+					"index":         0,
+					"finish_reason": "stop",
+					"message":       map[string]any{"role": "assistant", "content": reply},
+				},
+			},
+			"usage": map[string]any{"prompt_tokens": 100, "completion_tokens": 20, "total_tokens": 120},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLLMApi_GenerateAlertMessage_CustomEndpoint(t *testing.T) {
+	var received map[string]any
+	var headers http.Header
+	server := newChatServer(t, "db is down", &received, &headers)
+
+	temperature := 0.0
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{"db": {Type: "postgres"}},
+		LLM: config.LLMConfig{
+			BaseURL:        server.URL + "/v1",
+			Model:          "llama3",
+			APIKey:         "secret-key",
+			Headers:        map[string]string{"X-Tenant": "sre"},
+			Temperature:    &temperature,
+			MaxTokens:      512,
+			ResponseFormat: config.LLMResponseFormatText,
+		},
+	}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	msg, err := gen.GenerateAlertMessage(context.Background(), map[string]model.SubsystemInfo{
+		"db": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "db is down", msg.Text)
+	assert.Nil(t, msg.Analysis)
+	assert.NotContains(t, received, "response_format")
+
+	assert.Equal(t, "llama3", received["model"])
+	assert.Equal(t, 0.0, received["temperature"])
+	assert.Equal(t, 512.0, received["max_tokens"])
+	assert.Equal(t, "Bearer secret-key", headers.Get("Authorization"))
+	assert.Equal(t, "sre", headers.Get("X-Tenant"))
+}
+
+func TestNewLLMApi_YandexDefaults(t *testing.T) {
+	// Переменные окружения больше не читаются напрямую, только через ${VAR} в конфиге
+	t.Setenv("YANDEX_CLOUD_FOLDER", "env-folder")
+	t.Setenv("YANDEX_CLOUD_API_KEY", "env-key")
+
+	gen, err := NewLLMApi(&config.Config{LLM: config.LLMConfig{Folder: "folder", APIKey: "ya-key"}})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt://folder/yandexgpt/latest", gen.model)
+	assert.Equal(t, defaultTemperature, gen.temperature)
+
+	llmCfg, err := yandexDefaults(config.LLMConfig{Folder: "folder"})
+	require.NoError(t, err)
+	assert.Empty(t, llmCfg.APIKey)
+	assert.Equal(t, yaBaseUrl, llmCfg.BaseURL)
+	assert.Equal(t, "folder", llmCfg.Headers["OpenAI-Project"])
+
+	_, err = NewLLMApi(&config.Config{})
+	assert.ErrorContains(t, err, "llm.folder is required")
+}
+
+type failingGenerator struct{}
+
+func (failingGenerator) GenerateAlertMessage(context.Context, map[string]model.SubsystemInfo) (model.AlertMessage, error) {
+	return model.AlertMessage{}, errors.New("llm unavailable")
+}
+
+func testInfos() (*config.Config, map[string]model.SubsystemInfo) {
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {Type: "http", Deps: []string{"db"}},
+			"db":  {Type: "postgres"},
+			"web": {Type: "http"},
+		},
+	}
+	infos := map[string]model.SubsystemInfo{
+		"api": {Check: model.CheckResult{Status: model.PingStatusNotOk, Details: "http status code: 502"}},
+		"db": {
+			Check: model.CheckResult{Status: model.PingStatusNotOk, Details: "connection refused"},
+			Metric: model.MetricsExtractorResult{Metrics: []model.Metric{
+				{Name: "connections", Value: 3},
+				{Name: "latency", Value: 16, Baseline: &model.MetricBaseline{
+					Mode: config.BaselineModeStdDev, Mean: 10, StdDev: 1, Delta: 6, Score: 6, Anomalous: true,
+				}},
+			}},
+			Logs: model.LogExtractorResult{Lines: []model.LogLine{{Line: "FATAL: too many connections"}}},
+		},
+		"web": {Check: model.CheckResult{Status: model.PingStatusOk}},
+	}
+	return cfg, infos
+}
+
+func TestTemplateGenerator_DefaultTemplate(t *testing.T) {
+	cfg, infos := testInfos()
+
+	gen, err := NewTemplateGenerator(cfg)
+	require.NoError(t, err)
+
+	msg, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+
+	assert.Contains(t, msg.Text, "2 of 3 backends are unhealthy")
+	assert.Contains(t, msg.Text, "Likely root cause: db")
+	assert.Contains(t, msg.Text, "• db (postgres) [root]")
+	assert.Contains(t, msg.Text, "details: connection refused")
+	assert.Contains(t, msg.Text, "depends on: db")
+	assert.Contains(t, msg.Text, "> FATAL: too many connections")
+	assert.NotContains(t, msg.Text, "• web")
+
+	// Аномальная метрика идёт первой
+	assert.Less(t, strings.Index(msg.Text, "latency = 16 (6.0σ above baseline)"), strings.Index(msg.Text, "connections = 3"))
+}
+
+func TestTemplateGenerator_CustomTemplate(t *testing.T) {
+	cfg, infos := testInfos()
+	cfg.Alert.Template = `{{range .Failing}}{{.Name}}:{{.Status}};{{end}}`
+
+	gen, err := NewTemplateGenerator(cfg)
+	require.NoError(t, err)
+
+	msg, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	assert.Equal(t, "api:not_ok;db:not_ok;", msg.Text)
+}
+
+func TestFallbackGenerator(t *testing.T) {
+	cfg, infos := testInfos()
+	tmplGen, err := NewTemplateGenerator(cfg)
+	require.NoError(t, err)
+
+	msg, err := NewFallbackGenerator(failingGenerator{}, tmplGen).GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	assert.Contains(t, msg.Text, "Likely root cause: db")
+
+	_, err = NewFallbackGenerator(failingGenerator{}, failingGenerator{}).GenerateAlertMessage(context.Background(), infos)
+	assert.Error(t, err)
+}
+
+func TestNewAlertGenerator_TemplateOnly(t *testing.T) {
+	cfg, _ := testInfos()
+	cfg.Alert.Generator = config.AlertGeneratorTemplate
+
+	gen, err := NewAlertGenerator(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &templateGenerator{}, gen)
+}
+
+func TestNewAlertGenerator_LLMWithFallback(t *testing.T) {
+	cfg, _ := testInfos()
+
+	// Без секции llm остаются только шаблоны
+	gen, err := NewAlertGenerator(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &templateGenerator{}, gen)
+
+	cfg.LLM = config.LLMConfig{BaseURL: "http://ollama:11434/v1", Model: "llama3.1"}
+	gen, err = NewAlertGenerator(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &fallbackGenerator{}, gen)
+
+	// Ошибка в секции llm не прячется за шаблоном
+	cfg.LLM = config.LLMConfig{Model: "yandexgpt"}
+	_, err = NewAlertGenerator(cfg)
+	assert.EqualError(t, err, "llm.folder is required for Yandex Cloud without llm.base_url")
+
+	cfg.LLM = config.LLMConfig{BaseURL: "http://ollama:11434/v1"}
+	_, err = NewAlertGenerator(cfg)
+	assert.EqualError(t, err, "llm model not configured")
+}
+
+func TestLLMApi_GenerateAlertMessage_RedactsPrompt(t *testing.T) {
+	var received map[string]any
+	server := newChatServer(t, "ok", &received, nil)
+
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {
+				Type:    "http",
+				URL:     "http://api:8080/health",
+				Deps:    []string{"db"},
+				Headers: map[string]string{"Authorization": "Bearer api-secret-token"},
+			},
+			"db": {Type: "postgres", URL: "postgres://app:db-password@db:5432/app"},
+		},
+		Prometheus: config.PrometheusConfig{
+			URL:     "http://prometheus:9090",
+			Headers: map[string]string{"Authorization": "Bearer prom-secret"},
+		},
+		LLM: config.LLMConfig{
+			BaseURL:        server.URL + "/v1",
+			Model:          "llama3",
+			ResponseFormat: config.LLMResponseFormatText,
+		},
+	}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	_, err = gen.GenerateAlertMessage(context.Background(), map[string]model.SubsystemInfo{
+		"db": {Check: model.CheckResult{
+			Status:  model.PingStatusNotOk,
+			Details: "failed to connect to postgres://app:db-password@db:5432/app",
+		}},
+	})
+	require.NoError(t, err)
+
+	messages := received["messages"].([]any)
+	prompt := messages[0].(map[string]any)["content"].(string)
+
+	for _, secret := range []string{"api-secret-token", "db-password", "prom-secret", "http://api:8080", "prometheus:9090"} {
+		assert.NotContains(t, prompt, secret)
+	}
+	assert.Contains(t, prompt, "deps:")
+	assert.Contains(t, prompt, "- db")
+}
+
+const validAnalysis = `{
+	"root_cause_backend": "db",
+	"confidence": 0.85,
+	"summary": "Postgres refuses connections, api fails because it depends on db.",
+	"remediation_steps": ["Check max_connections on db", "Restart api after db recovers"]
+}`
+
+func TestLLMApi_GenerateAlertMessage_StructuredAnalysis(t *testing.T) {
+	var received map[string]any
+	server := newChatServer(t, "```json\n"+validAnalysis+"\n```", &received, nil)
+
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: server.URL + "/v1", Model: "llama3"}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	msg, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+
+	require.NotNil(t, msg.Analysis)
+	assert.Equal(t, "db", msg.Analysis.RootCauseBackend)
+	assert.Equal(t, 0.85, msg.Analysis.Confidence)
+	assert.Len(t, msg.Analysis.RemediationSteps, 2)
+
+	assert.True(t, strings.HasPrefix(msg.Text, "Root cause: db (confidence 85%)"))
+	assert.Contains(t, msg.Text, "1. Check max_connections on db\n2. Restart api after db recovers")
+	assert.Contains(t, msg.Text, "Recent logs:")
+
+	format := received["response_format"].(map[string]any)
+	assert.Equal(t, "json_schema", format["type"])
+	schema := format["json_schema"].(map[string]any)
+	assert.Equal(t, analysisSchemaName, schema["name"])
+	assert.Equal(t, true, schema["strict"])
+}
+
+func TestLLMApi_GenerateAlertMessage_RetriesMalformedAnalysis(t *testing.T) {
+	var requests []map[string]any
+	server := newScriptedChatServer(t,
+		[]string{"db is down", `{"root_cause_backend": "cache", "confidence": 0.5, "summary": "x", "remediation_steps": ["y"]}`, validAnalysis},
+		func(body map[string]any, _ http.Header) { requests = append(requests, body) },
+	)
+
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{
+		BaseURL:        server.URL + "/v1",
+		Model:          "llama3",
+		ResponseFormat: config.LLMResponseFormatJSONObject,
+	}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	msg, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	require.NotNil(t, msg.Analysis)
+	assert.Equal(t, "db", msg.Analysis.RootCauseBackend)
+
+	require.Len(t, requests, 3)
+	assert.Equal(t, "json_object", requests[0]["response_format"].(map[string]any)["type"])
+
+	// Невалидные ответы и причина ошибки возвращаются модели
+	messages := requests[2]["messages"].([]any)
+	require.Len(t, messages, 5)
+	assert.Equal(t, "db is down", messages[1].(map[string]any)["content"])
+	assert.Contains(t, messages[4].(map[string]any)["content"], `unknown root_cause_backend "cache"`)
+}
+
+func TestLLMApi_GenerateAlertMessage_GivesUpOnInvalidAnalysis(t *testing.T) {
+	var calls int
+	server := newScriptedChatServer(t, []string{`{"summary": ""}`}, func(map[string]any, http.Header) { calls++ })
+
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: server.URL + "/v1", Model: "llama3"}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	_, err = gen.GenerateAlertMessage(context.Background(), infos)
+	assert.ErrorContains(t, err, "invalid analysis after 3 attempts")
+	assert.Equal(t, maxAnalysisAttempts, calls)
+}
+
+func TestParseAnalysis(t *testing.T) {
+	_, infos := testInfos()
+
+	for name, content := range map[string]string{
+		"not json":         "the database is down",
+		"unknown field":    `{"root_cause_backend": "db", "confidence": 1, "summary": "s", "remediation_steps": ["r"], "extra": 1}`,
+		"confidence range": `{"root_cause_backend": "db", "confidence": 85, "summary": "s", "remediation_steps": ["r"]}`,
+		"empty summary":    `{"root_cause_backend": "db", "confidence": 1, "summary": " ", "remediation_steps": ["r"]}`,
+		"no steps":         `{"root_cause_backend": "db", "confidence": 1, "summary": "s", "remediation_steps": []}`,
+		"blank step":       `{"root_cause_backend": "db", "confidence": 1, "summary": "s", "remediation_steps": [""]}`,
+	} {
+		_, err := parseAnalysis(content, infos)
+		assert.Error(t, err, name)
+	}
+
+	analysis, err := parseAnalysis(`{"root_cause_backend": "", "confidence": 0.1, "summary": "s", "remediation_steps": ["r"]}`, infos)
+	require.NoError(t, err)
+	assert.Equal(t, "Root cause: unknown\ns\n\nRemediation:\n1. r", renderAnalysis(analysis))
+}
+
+func TestBuildPrompt_ServiceContext(t *testing.T) {
+	cfg, infos := testInfos()
+	db := cfg.Backends["db"]
+	db.Description = "Primary Postgres for orders"
+	db.Owner = "team-storage"
+	db.RunbookURL = "https://wiki.example.com/runbooks/db"
+	db.Notes = `Often runs out of connections after "api" deploys`
+	cfg.Backends["db"] = db
+
+	tmpl, err := loadPromptTemplate(config.LLMConfig{})
+	require.NoError(t, err)
+
+	prompt, err := buildPrompt(tmpl, cfg, infos, true)
+	require.NoError(t, err)
+
+	assert.Contains(t, prompt, "## 1.1. Service Context")
+	assert.Contains(t, prompt, "### db\n- Description: Primary Postgres for orders\n- Owner: team-storage")
+	assert.Contains(t, prompt, "- Runbook: https://wiki.example.com/runbooks/db")
+	// text/template не экранирует кавычки, в отличие от html/template
+	assert.Contains(t, prompt, `after "api" deploys`)
+	assert.NotContains(t, prompt, "### api")
+}
+
+func TestLLMApi_GenerateAlertMessage_PromptTemplateFile(t *testing.T) {
+	var received map[string]any
+	server := newChatServer(t, "ok", &received, nil)
+
+	tmplFile := filepath.Join(t.TempDir(), "prompt.tmpl")
+	require.NoError(t, os.WriteFile(tmplFile, []byte(
+		`{{range .Services}}{{.Name}}={{.Status}} owner={{.Owner}};{{end}}`,
+	), 0600))
+
+	cfg, infos := testInfos()
+	api := cfg.Backends["api"]
+	api.Owner = "team-web"
+	cfg.Backends["api"] = api
+	cfg.LLM = config.LLMConfig{
+		BaseURL:            server.URL + "/v1",
+		Model:              "llama3",
+		ResponseFormat:     config.LLMResponseFormatText,
+		PromptTemplate:     "ignored",
+		PromptTemplateFile: tmplFile,
+	}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	_, err = gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+
+	messages := received["messages"].([]any)
+	assert.Equal(t,
+		"api=not_ok owner=team-web;db=not_ok owner=;web=ok owner=;",
+		messages[0].(map[string]any)["content"],
+	)
+
+	cfg.LLM.PromptTemplateFile = ""
+	cfg.LLM.PromptTemplate = "{{.Broken"
+	_, err = NewLLMApi(cfg)
+	assert.ErrorContains(t, err, "parse prompt template")
+}
+
+func TestBuildPrompt_Timeline(t *testing.T) {
+	cfg, infos := testInfos()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db := infos["db"]
+	db.Timeline = []model.StatusChange{
+		{At: start, To: model.PingStatusOk},
+		{At: start.Add(10 * time.Second), From: model.PingStatusOk, To: model.PingStatusNotOk, Details: "connection refused"},
+	}
+	infos["db"] = db
+	api := infos["api"]
+	api.Timeline = []model.StatusChange{
+		{At: start, To: model.PingStatusOk},
+		{At: start.Add(50 * time.Second), From: model.PingStatusOk, To: model.PingStatusNotOk},
+	}
+	infos["api"] = api
+
+	tmpl, err := loadPromptTemplate(config.LLMConfig{})
+	require.NoError(t, err)
+
+	prompt, err := buildPrompt(tmpl, cfg, infos, true)
+	require.NoError(t, err)
+
+	assert.Contains(t, prompt, "## 2.1. Status Timeline")
+	assert.Contains(t, prompt,
+		"2024-01-01T12:00:10Z db: ok -> not_ok (connection refused)\n"+
+			"2024-01-01T12:00:50Z api: ok -> not_ok\n")
+	assert.NotContains(t, prompt, "first check -> ok")
+	assert.Contains(t, prompt,
+		"1. db at 2024-01-01T12:00:10Z\n"+
+			"2. api at 2024-01-01T12:00:50Z, 40s after db\n")
+
+	// Таблица статусов больше не зависит от порядка обхода map
+	assert.Contains(t, prompt, "|api       | not_ok |\n|db        | not_ok |\n|web       |     ok |\n")
+}
+
+// newBlockingChatServer never answers until the client gives up.
+func newBlockingChatServer(t *testing.T) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	// Cleanup выполняется в обратном порядке: сначала отпускаем обработчик, потом закрываем сервер
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server
+}
+
+func TestLLMApi_GenerateAlertMessage_HonoursContext(t *testing.T) {
+	server := newBlockingChatServer(t)
+
+	noRetries := 0
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: server.URL + "/v1", Model: "llama3", MaxRetries: &noRetries}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err = gen.GenerateAlertMessage(ctx, infos)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
+
+func TestLLMApi_GenerateAlertMessage_Timeout(t *testing.T) {
+	server := newBlockingChatServer(t)
+
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: server.URL + "/v1", Model: "timeout-model", Timeout: 100 * time.Millisecond}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	timeouts := testutil.ToFloat64(llmRequests.WithLabelValues("timeout-model", "timeout"))
+
+	started := time.Now()
+	_, err = gen.GenerateAlertMessage(context.Background(), infos)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assert.Equal(t, timeouts+1, testutil.ToFloat64(llmRequests.WithLabelValues("timeout-model", "timeout")))
+}
+
+func TestLLMApi_GenerateAlertMessage_RetriesRateLimit(t *testing.T) {
+	var calls int
+	ok := newChatServer(t, validAnalysis, nil, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After-Ms", "10")
+			http.Error(w, `{"error": {"message": "rate limited"}}`, http.StatusTooManyRequests)
+			return
+		}
+		ok.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: server.URL + "/v1", Model: "retry-model"}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	msg, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	assert.NotNil(t, msg.Analysis)
+	assert.Equal(t, 2, calls)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(llmRequests.WithLabelValues("retry-model", "success")))
+	assert.Equal(t, 100.0, testutil.ToFloat64(llmTokens.WithLabelValues("retry-model", "prompt")))
+	assert.Equal(t, 20.0, testutil.ToFloat64(llmTokens.WithLabelValues("retry-model", "completion")))
+
+	// Без ретраев 429 сразу возвращается ошибкой
+	calls = 0
+	noRetries := 0
+	cfg.LLM.MaxRetries = &noRetries
+	gen, err = NewLLMApi(cfg)
+	require.NoError(t, err)
+
+	_, err = gen.GenerateAlertMessage(context.Background(), infos)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestLLMApi_GenerateAlertMessage_CachesByFingerprint(t *testing.T) {
+	var calls int
+	server := newScriptedChatServer(t, []string{validAnalysis}, func(map[string]any, http.Header) { calls++ })
+
+	cfg, infos := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: server.URL + "/v1", Model: "cache-model", CacheTTL: time.Minute}
+
+	gen, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	gen.cache.now = func() time.Time { return now }
+
+	first, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+
+	// Тот же инцидент с другими логами: анализ из кэша, логи свежие
+	db := infos["db"]
+	db.Logs.Lines = []model.LogLine{{Line: "FATAL: remaining connection slots are reserved"}}
+	infos["db"] = db
+	second, err := gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first.Analysis, second.Analysis)
+	assert.Contains(t, second.Text, "remaining connection slots")
+	assert.NotContains(t, second.Text, "too many connections")
+	assert.Equal(t, 1.0, testutil.ToFloat64(llmCacheHits.WithLabelValues("cache-model")))
+
+	// Картина изменилась: упал ещё один бэкенд
+	web := infos["web"]
+	web.Check = model.CheckResult{Status: model.PingStatusNotOk, Details: "http status code: 503"}
+	infos["web"] = web
+	_, err = gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// TTL истёк
+	now = now.Add(2 * time.Minute)
+	_, err = gen.GenerateAlertMessage(context.Background(), infos)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestKeepCache(t *testing.T) {
+	cfg, _ := testInfos()
+	cfg.LLM = config.LLMConfig{BaseURL: "http://llm.local/v1", Model: "keep-model", CacheTTL: time.Minute}
+	msg := model.AlertMessage{Text: "db is down"}
+
+	prev, err := NewLLMApi(cfg)
+	require.NoError(t, err)
+	prev.cache.put("fp", msg)
+
+	// Перезагрузка без изменений llm сохраняет анализы, в том числе за fallback
+	reloaded := *cfg
+	tmplGen, err := NewTemplateGenerator(&reloaded)
+	require.NoError(t, err)
+	next, err := NewLLMApi(&reloaded)
+	require.NoError(t, err)
+	KeepCache(NewFallbackGenerator(prev, tmplGen), NewFallbackGenerator(next, tmplGen))
+	cached, ok := next.cache.get("fp")
+	assert.True(t, ok)
+	assert.Equal(t, msg, cached)
+
+	// Другая модель отвечает по-другому, её кэш начинается заново
+	changed := *cfg
+	changed.LLM.Model = "other-model"
+	next, err = NewLLMApi(&changed)
+	require.NoError(t, err)
+	KeepCache(prev, next)
+	_, ok = next.cache.get("fp")
+	assert.False(t, ok)
+
+	KeepCache(tmplGen, next)
+	KeepCache(nil, next)
+}
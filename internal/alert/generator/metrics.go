@@ -0,0 +1,58 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	openai "github.com/openai/openai-go/v3"
+)
+
+var (
+	llmRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pingr",
+		Subsystem: "llm",
+		Name:      "requests_total",
+		Help:      "Chat completion calls by outcome: success, error or timeout. SDK retries are not counted separately.",
+	}, []string{"model", "outcome"})
+
+	llmRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pingr",
+		Subsystem: "llm",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of chat completion calls including retries.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"model"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pingr",
+		Subsystem: "llm",
+		Name:      "tokens_total",
+		Help:      "Tokens used by chat completion calls, by kind: prompt or completion.",
+	}, []string{"model", "kind"})
+
+	llmCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pingr",
+		Subsystem: "llm",
+		Name:      "cache_hits_total",
+		Help:      "Alerts that reused a cached analysis of the same incident fingerprint.",
+	}, []string{"model"})
+)
+
+func observeCompletion(model string, started time.Time, resp *openai.ChatCompletion, err error) {
+	llmRequestDuration.WithLabelValues(model).Observe(time.Since(started).Seconds())
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		llmRequests.WithLabelValues(model, "timeout").Inc()
+	case err != nil:
+		llmRequests.WithLabelValues(model, "error").Inc()
+	default:
+		llmRequests.WithLabelValues(model, "success").Inc()
+		llmTokens.WithLabelValues(model, "prompt").Add(float64(resp.Usage.PromptTokens))
+		llmTokens.WithLabelValues(model, "completion").Add(float64(resp.Usage.CompletionTokens))
+	}
+}
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/incident"
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/redact"
+	"github.com/unicoooorn/pingr/internal/service"
+)
+
+const (
+	// Сколько метрик на бэкенд попадает в KeyMetrics
+	keyMetricsLimit = 5
+
+	defaultAlertTemplate = `🔴 {{len .Failing}} of {{len .Backends}} backends are unhealthy
+{{- if .Roots}}
+Likely root cause: {{join .Roots ", "}}
+{{- end}}
+{{range .Failing}}
+• {{.Name}}{{if .Type}} ({{.Type}}){{end}}{{if .Root}} [root]{{end}}
+  {{- if .Details}}
+  details: {{.Details}}
+  {{- end}}
+  {{- if .Deps}}
+  depends on: {{join .Deps ", "}}
+  {{- end}}
+  {{- range .KeyMetrics}}
+  {{.}}
+  {{- end}}
+  {{- range .Logs}}
+  > {{.Line}}
+  {{- end}}
+{{end}}`
+)
+
+var _ service.AlertGenerator = &templateGenerator{}
+
+// TemplateData is available to alert message templates.
+type TemplateData struct {
+	Backends []BackendSummary
+	Failing  []BackendSummary
+	Healthy  []BackendSummary
+	// Roots are failing backends whose dependencies are all healthy.
+	Roots []string
+}
+
+type BackendSummary struct {
+	Name    string
+	Type    string
+	Status  model.PingStatus
+	Details string
+	Deps    []string
+	Root    bool
+	// KeyMetrics are formatted metrics, anomalous ones first.
+	KeyMetrics []string
+	Logs       []model.LogLine
+}
+
+// templateGenerator renders alerts without an LLM, so it works during AI provider outages.
+type templateGenerator struct {
+	tmpl     *template.Template
+	config   *config.Config
+	redactor *redact.Redactor
+}
+
+func NewTemplateGenerator(config *config.Config) (*templateGenerator, error) {
+	text := config.Alert.Template
+	if config.Alert.TemplateFile != "" {
+		b, err := os.ReadFile(config.Alert.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("read alert template: %w", err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		text = defaultAlertTemplate
+	}
+
+	tmpl, err := template.New("alert").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse alert template: %w", err)
+	}
+
+	redactor, err := redact.New(*config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &templateGenerator{
+		tmpl:     tmpl,
+		config:   config,
+		redactor: redactor,
+	}, nil
+}
+
+func (g *templateGenerator) GenerateAlertMessage(
+	ctx context.Context,
+	subsystemInfoByName map[string]model.SubsystemInfo,
+) (model.AlertMessage, error) {
+	data := buildTemplateData(g.config, subsystemInfoByName)
+
+	var sb strings.Builder
+	if err := g.tmpl.Execute(&sb, data); err != nil {
+		return model.AlertMessage{}, fmt.Errorf("execute alert template: %w", err)
+	}
+	return model.AlertMessage{Text: g.redactor.Redact(strings.TrimSpace(sb.String()))}, nil
+}
+
+func buildTemplateData(cfg *config.Config, subsystemInfoByName map[string]model.SubsystemInfo) TemplateData {
+	roots := incident.Roots(*cfg, subsystemInfoByName)
+	isRoot := make(map[string]bool, len(roots))
+	for _, name := range roots {
+		isRoot[name] = true
+	}
+
+	names := make([]string, 0, len(subsystemInfoByName))
+	for name := range subsystemInfoByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := TemplateData{Roots: roots}
+	for _, name := range names {
+		info := subsystemInfoByName[name]
+		summary := BackendSummary{
+			Name:       name,
+			Type:       cfg.Backends[name].Type,
+			Status:     info.Check.Status,
+			Details:    info.Check.Details,
+			Deps:       cfg.Backends[name].Deps,
+			Root:       isRoot[name],
+			KeyMetrics: keyMetrics(info.Metric.Metrics),
+			Logs:       info.Logs.Lines,
+		}
+		if len(summary.Logs) > messageLogLines {
+			summary.Logs = summary.Logs[:messageLogLines]
+		}
+
+		data.Backends = append(data.Backends, summary)
+		if info.Check.Status == model.PingStatusNotOk {
+			data.Failing = append(data.Failing, summary)
+		} else {
+			data.Healthy = append(data.Healthy, summary)
+		}
+	}
+	return data
+}
+
+func keyMetrics(metrics []model.Metric) []string {
+	sorted := append([]model.Metric(nil), metrics...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return isAnomalous(sorted[i]) && !isAnomalous(sorted[j])
+	})
+
+	var res []string
+	for _, metric := range sorted {
+		if len(res) == keyMetricsLimit {
+			break
+		}
+		line := fmt.Sprintf("%s = %g", metric.Name, metric.Value)
+		if metric.Baseline != nil {
+			line += fmt.Sprintf(" (%s)", metric.Baseline)
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+func isAnomalous(metric model.Metric) bool {
+	return metric.Baseline != nil && metric.Baseline.Anomalous
+}
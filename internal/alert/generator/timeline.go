@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+// Сколько последних смен статуса всех бэкендов попадает в промпт
+const promptTimelineEntries = 50
+
+type timelineEntry struct {
+	backend string
+	change  model.StatusChange
+}
+
+// formatTimeline merges status transitions of all backends, oldest first.
+// The first check of a healthy backend carries no information and is skipped.
+func formatTimeline(subsystemInfoByName map[string]model.SubsystemInfo) string {
+	var entries []timelineEntry
+	for name, info := range subsystemInfoByName {
+		for _, change := range info.Timeline {
+			if change.From == "" && change.To == model.PingStatusOk {
+				continue
+			}
+			entries = append(entries, timelineEntry{backend: name, change: change})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].change.At.Equal(entries[j].change.At) {
+			return entries[i].change.At.Before(entries[j].change.At)
+		}
+		return entries[i].backend < entries[j].backend
+	})
+	if len(entries) > promptTimelineEntries {
+		entries = entries[len(entries)-promptTimelineEntries:]
+	}
+
+	var sb strings.Builder
+	for _, e := range entries {
+		from := string(e.change.From)
+		if from == "" {
+			from = "first check"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s: %s -> %s", formatTime(e.change.At), e.backend, from, e.change.To))
+		if e.change.Details != "" {
+			sb.WriteString(fmt.Sprintf(" (%s)", e.change.Details))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// failingSince returns when the backend last went red, zero if it is not failing.
+func failingSince(info model.SubsystemInfo) time.Time {
+	if info.Check.Status != model.PingStatusNotOk {
+		return time.Time{}
+	}
+	for i := len(info.Timeline) - 1; i >= 0; i-- {
+		if info.Timeline[i].To == model.PingStatusNotOk {
+			return info.Timeline[i].At
+		}
+	}
+	return time.Time{}
+}
+
+// formatFailureOrder lists failing backends in the order they went red,
+// e.g. "2. api at 12:00:40Z, 40s after db".
+func formatFailureOrder(subsystemInfoByName map[string]model.SubsystemInfo) string {
+	type failure struct {
+		backend string
+		at      time.Time
+	}
+	var failures []failure
+	for name, info := range subsystemInfoByName {
+		if at := failingSince(info); !at.IsZero() {
+			failures = append(failures, failure{backend: name, at: at})
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if !failures[i].at.Equal(failures[j].at) {
+			return failures[i].at.Before(failures[j].at)
+		}
+		return failures[i].backend < failures[j].backend
+	})
+
+	var sb strings.Builder
+	for i, f := range failures {
+		sb.WriteString(fmt.Sprintf("%d. %s at %s", i+1, f.backend, formatTime(f.at)))
+		if i > 0 {
+			first := failures[0]
+			sb.WriteString(fmt.Sprintf(", %s after %s", f.at.Sub(first.at).Round(time.Second), first.backend))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
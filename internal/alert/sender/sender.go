@@ -26,7 +26,13 @@ type tgApi struct {
 	chatId string
 }
 
+const defaultApiUrl = "https://api.telegram.org"
+
+// NewTgApi creates a Bot API sender; an empty url means the public Bot API.
 func NewTgApi(url string, token string, chatId string) *tgApi {
+	if url == "" {
+		url = defaultApiUrl
+	}
 	return &tgApi{
 		url:    url,
 		token:  token,
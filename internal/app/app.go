@@ -4,13 +4,13 @@ import (
 	"context"
 	"fmt"
 	"time"
-	"os"
 
 	"github.com/unicoooorn/pingr/internal/alert/generator"
 	"github.com/unicoooorn/pingr/internal/alert/sender"
 	"github.com/unicoooorn/pingr/internal/checker"
 	"github.com/unicoooorn/pingr/internal/config"
 	"github.com/unicoooorn/pingr/internal/infographics"
+	"github.com/unicoooorn/pingr/internal/log_extractor"
 	metrics_extractor "github.com/unicoooorn/pingr/internal/metrics_extactor"
 	"github.com/unicoooorn/pingr/internal/scheduler"
 	"github.com/unicoooorn/pingr/internal/service"
@@ -23,28 +23,89 @@ type Scheduler interface {
 	StartMonitoring(ctx context.Context) error
 }
 
-func Run(ctx context.Context, cfg config.Config) error {
+// Run monitors the backends from cfg. When configPath is set, the config is
+// reloaded from it on change and on SIGHUP.
+func Run(ctx context.Context, cfg config.Config, configPath string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	metricsExtractor, err := metrics_extractor.NewPrometheusMetricsExtractor(cfg.Prometheus)
+	if cfg.Telemetry.ListenAddr != "" {
+		go serveTelemetry(ctx, cfg.Telemetry.ListenAddr)
+	}
+
+	components, err := newComponents(cfg)
 	if err != nil {
-		return fmt.Errorf("unable to extract metrics: %w", err)
+		return err
+	}
+
+	svc := service.New(
+		components.Checker,
+		components.AlertSender,
+		components.AlertGenerator,
+		components.MetricsExtractor,
+		components.LogExtractor,
+		components.InfographicsRenderer,
+		cfg,
+	)
+
+	if configPath != "" {
+		go watchConfig(ctx, configPath, components, svc)
 	}
 
-	tgApiUrl := os.Getenv("TG_API_URL")
-	tgToken := os.Getenv("TG_TOKEN")
-	tgChatId := os.Getenv("TG_CHAT_ID")
-
-	return scheduler.NewFixedIntervalScheduler(
-		service.New(
-			checker.NewChecker(&cfg),
-			sender.NewTgApi(tgApiUrl, tgToken, tgChatId),
-			generator.NewLLMApi(&cfg),
-			metricsExtractor,
-			infographics.NewImageRenderer(cfg, time.Second*10),
-			cfg,
-		),
+	err = scheduler.NewFixedIntervalScheduler(
+		svc,
 		10*time.Second,
 	).StartMonitoring(ctx)
+	// Отменённый контекст прерывает и идущий алерт, дожидаемся его завершения
+	svc.Wait()
+	return err
+}
+
+// Validate builds everything Run would build from cfg without starting it, to
+// catch problems the config schema can't see, e.g. a prompt template that
+// doesn't parse or a missing metrics datasource.
+func Validate(cfg config.Config) error {
+	_, err := newComponents(cfg)
+	return err
+}
+
+// newComponents собирает всё, что зависит от конфига и пересоздаётся при перезагрузке.
+func newComponents(cfg config.Config) (service.Components, error) {
+	metricsExtractor, err := metrics_extractor.NewRouter(cfg)
+	if err != nil {
+		return service.Components{}, fmt.Errorf("unable to extract metrics: %w", err)
+	}
+
+	var logExtractor service.LogExtractor
+	if cfg.Loki.URL != "" {
+		lokiExtractor, err := log_extractor.NewLokiLogExtractor(cfg.Loki)
+		if err != nil {
+			return service.Components{}, fmt.Errorf("unable to extract logs: %w", err)
+		}
+		logExtractor = lokiExtractor.WithBackends(cfg.Backends)
+	}
+
+	alertGenerator, err := generator.NewAlertGenerator(&cfg)
+	if err != nil {
+		return service.Components{}, fmt.Errorf("unable to generate alerts: %w", err)
+	}
+
+	var infographicsRenderer service.InfographicsRenderer
+	if !cfg.Infographics.Disabled {
+		imageRenderer, err := infographics.NewImageRenderer(cfg, time.Second*10)
+		if err != nil {
+			return service.Components{}, fmt.Errorf("unable to render infographics: %w", err)
+		}
+		infographicsRenderer = imageRenderer
+	}
+
+	return service.Components{
+		Checker:              checker.NewChecker(&cfg),
+		AlertSender:          sender.NewTgApi(cfg.Telegram.APIURL, cfg.Telegram.Token, cfg.Telegram.ChatID),
+		AlertGenerator:       alertGenerator,
+		MetricsExtractor:     metricsExtractor,
+		LogExtractor:         logExtractor,
+		InfographicsRenderer: infographicsRenderer,
+		Config:               cfg,
+	}, nil
 }
@@ -0,0 +1,13 @@
+package app
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var configReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "pingr",
+	Subsystem: "config",
+	Name:      "reloads_total",
+	Help:      "Config reloads by result; a failed reload keeps the running config.",
+}, []string{"result"})
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/alert/generator"
+	"github.com/unicoooorn/pingr/internal/config"
+	metrics_extractor "github.com/unicoooorn/pingr/internal/metrics_extactor"
+	"github.com/unicoooorn/pingr/internal/service"
+)
+
+// Редактор пишет файл несколькими событиями, перечитываем после последнего
+const reloadDebounce = 500 * time.Millisecond
+
+type reloader interface {
+	Reload(c service.Components)
+}
+
+// watchConfig перечитывает конфиг при изменении его файлов и по SIGHUP.
+// Невалидный конфиг отклоняется, мониторинг продолжается со старым. Новые
+// файлы в подключённых каталогах видны только после перезагрузки.
+// current — компоненты, с которыми запущен сервис, из них переносятся кэши.
+func watchConfig(ctx context.Context, configPath string, current service.Components, svc reloader) {
+	triggers := make(chan struct{}, 1)
+	trigger := func() {
+		select {
+		case triggers <- struct{}{}:
+		default:
+		}
+	}
+
+	// SIGHUP перехватывается сразу, иначе он завершит процесс
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	watcher, err := config.NewWatcher()
+	if err != nil {
+		slog.Error("config files are not watched, reload with SIGHUP", "error", err)
+	} else {
+		go watcher.Run(ctx, trigger)
+	}
+	watch := func(files []string) {
+		if watcher == nil {
+			return
+		}
+		for _, file := range files {
+			if err := watcher.Add(file); err != nil {
+				slog.Error("config file is not watched", "error", err)
+			}
+		}
+	}
+	watch(append([]string{configPath}, current.Config.Files...))
+
+	started := current.Config
+	reload := func() {
+		if next, ok := reloadConfig(configPath, current, svc); ok {
+			current = next
+			warnRestartRequired(started, next.Config)
+		}
+		watch(current.Config.Files)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("received SIGHUP, reloading config")
+			reload()
+		case <-triggers:
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reloadDebounce):
+			}
+			select {
+			case <-triggers:
+			default:
+			}
+			slog.Info("config files changed, reloading", "path", configPath)
+			reload()
+		}
+	}
+}
+
+// reloadConfig собирает компоненты нового конфига и подменяет ими current.
+// Кэши LLM-анализов и запросов метрик переносятся, если их настройки не
+// изменились, чтобы перезагрузка не повторяла запросы по тому же инциденту.
+func reloadConfig(configPath string, current service.Components, svc reloader) (service.Components, bool) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		slog.Error("config reload rejected, keeping the running config", "error", err)
+		configReloads.WithLabelValues("failure").Inc()
+		return current, false
+	}
+
+	components, err := newComponents(*cfg)
+	if err != nil {
+		slog.Error("config reload rejected, keeping the running config", "error", err)
+		configReloads.WithLabelValues("failure").Inc()
+		return current, false
+	}
+	keepCaches(current, components)
+
+	svc.Reload(components)
+	configReloads.WithLabelValues("success").Inc()
+	slog.Info("config reloaded", "backends", len(cfg.Backends))
+	return components, true
+}
+
+// warnRestartRequired предупреждает об изменённых настройках, которые
+// применяются только при запуске, чтобы они не терялись молча.
+func warnRestartRequired(started, next config.Config) {
+	if next.Telemetry.ListenAddr != started.Telemetry.ListenAddr {
+		slog.Warn("telemetry.listen_addr changed, restart pingr to apply it",
+			"running", started.Telemetry.ListenAddr, "configured", next.Telemetry.ListenAddr)
+	}
+}
+
+func keepCaches(prev, next service.Components) {
+	generator.KeepCache(prev.AlertGenerator, next.AlertGenerator)
+
+	prevRouter, ok := prev.MetricsExtractor.(*metrics_extractor.Router)
+	if !ok {
+		return
+	}
+	if nextRouter, ok := next.MetricsExtractor.(*metrics_extractor.Router); ok {
+		nextRouter.KeepCache(prevRouter)
+	}
+}
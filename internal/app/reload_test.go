@@ -0,0 +1,118 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/service"
+)
+
+type reloadRecorder struct {
+	reloads chan service.Components
+}
+
+func (r *reloadRecorder) Reload(c service.Components) {
+	r.reloads <- c
+}
+
+func (r *reloadRecorder) next(t *testing.T) service.Components {
+	t.Helper()
+	select {
+	case c := <-r.reloads:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+		return service.Components{}
+	}
+}
+
+func (r *reloadRecorder) none(t *testing.T) {
+	t.Helper()
+	select {
+	case c := <-r.reloads:
+		t.Fatalf("unexpected reload with backends %v", c.Config.Backends)
+	case <-time.After(2 * reloadDebounce):
+	}
+}
+
+func reloadTestConfig(backends ...string) string {
+	yaml := "prometheus:\n  url: http://prometheus:9090\nbackends:\n"
+	for _, name := range backends {
+		yaml += "  " + name + ":\n    type: http\n    url: http://" + name + "/health\n"
+	}
+	return yaml
+}
+
+// Тест перезагрузки: правка файла, замена переименованием, как делают
+// редакторы, невалидный конфиг и SIGHUP
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(reloadTestConfig("api")), 0644))
+
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+	components, err := newComponents(*cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := &reloadRecorder{reloads: make(chan service.Components, 4)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchConfig(ctx, configPath, components, recorder)
+	}()
+	// Даём наблюдателю подписаться на каталог
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(configPath, []byte(reloadTestConfig("api", "db")), 0644))
+	assert.Len(t, recorder.next(t).Config.Backends, 2)
+
+	tmp := filepath.Join(dir, ".config.yaml.swp")
+	require.NoError(t, os.WriteFile(tmp, []byte(reloadTestConfig("api", "db", "cache")), 0644))
+	require.NoError(t, os.Rename(tmp, configPath))
+	assert.Len(t, recorder.next(t).Config.Backends, 3)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("backends: [\n"), 0644))
+	recorder.none(t)
+
+	// Файл удалили и создали заново: наблюдение продолжается
+	require.NoError(t, os.Remove(configPath))
+	recorder.none(t)
+	require.NoError(t, os.WriteFile(configPath, []byte(reloadTestConfig("web")), 0644))
+	assert.Contains(t, recorder.next(t).Config.Backends, "web")
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	assert.Contains(t, recorder.next(t).Config.Backends, "web")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchConfig did not stop")
+	}
+}
+
+func TestWarnRestartRequired(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	started := config.Config{Telemetry: config.TelemetryConfig{ListenAddr: ":9464"}}
+	warnRestartRequired(started, started)
+	assert.Empty(t, logs.String())
+
+	warnRestartRequired(started, config.Config{Telemetry: config.TelemetryConfig{ListenAddr: ":9465"}})
+	assert.Contains(t, logs.String(), `level=WARN msg="telemetry.listen_addr changed, restart pingr to apply it" running=:9464 configured=:9465`)
+}
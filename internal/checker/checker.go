@@ -3,6 +3,8 @@ package checker
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/unicoooorn/pingr/internal/config"
@@ -29,35 +31,35 @@ func (r *CheckerImpl) Check(ctx context.Context, subsystem string) (model.CheckR
 		return model.CheckResult{Status: model.PingStatusNotOk, Details: "type empty"}, fmt.Errorf("type empty or not found for '%s'", subsystem)
 	}
 
-	addr := fmt.Sprintf("%s:%d", subsystem_cfg.Host, subsystem_cfg.Port)
+	addr := net.JoinHostPort(subsystem_cfg.Host, strconv.Itoa(subsystem_cfg.Port))
 
 	switch strings.ToLower(subsystem_cfg.Type) {
-	case "http":
+	case config.BackendTypeHTTP:
 		if subsystem_cfg.URL == "" {
 			return model.CheckResult{}, fmt.Errorf("http checker: missing url")
 		}
 		return CheckHttpHealth(ctx, subsystem_cfg.URL, subsystem_cfg.Headers, subsystem_cfg.Timeout), nil
-	case "grpc":
-		if subsystem_cfg.URL == "" {
-			return model.CheckResult{}, fmt.Errorf("postgres checker: missing url (DSN)")
+	case config.BackendTypeGRPC:
+		if subsystem_cfg.Host == "" || subsystem_cfg.Port == 0 {
+			return model.CheckResult{}, fmt.Errorf("grpc checker: missing host and/or port")
 		}
 		return CheckGrpcHealth(ctx, addr, subsystem_cfg.Timeout), nil
-	case "icmp":
+	case config.BackendTypeICMP:
 		if subsystem_cfg.Host == "" {
 			return model.CheckResult{}, fmt.Errorf("icmp checker: missing host")
 		}
 		return CheckIcmpHealth(ctx, subsystem_cfg.Host, subsystem_cfg.Timeout), nil
-	case "tcp":
+	case config.BackendTypeTCP:
 		if subsystem_cfg.Host == "" || subsystem_cfg.Port == 0 {
 			return model.CheckResult{}, fmt.Errorf("tcp checker: missing host and/or port")
 		}
 		return CheckTcpHealth(ctx, subsystem_cfg.Host, subsystem_cfg.Port, subsystem_cfg.Timeout), nil
-	case "redis":
+	case config.BackendTypeRedis:
 		if subsystem_cfg.Host == "" || subsystem_cfg.Port == 0 {
 			return model.CheckResult{}, fmt.Errorf("redis checker: missing host and/or port")
 		}
 		return CheckRedisHealth(ctx, addr, subsystem_cfg.Timeout), nil
-	case "postgres":
+	case config.BackendTypePostgres:
 		if subsystem_cfg.URL == "" {
 			return model.CheckResult{}, fmt.Errorf("postgres checker: missing url (DSN)")
 		}
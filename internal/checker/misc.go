@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/go-ping/ping"
@@ -84,7 +85,7 @@ func CheckIcmpHealth(
 }
 
 func CheckTcpHealth(ctx context.Context, host string, port int, timeout time.Duration) model.CheckResult {
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	conn, err := net.DialTimeout("tcp", addr, timeout)
 	if err != nil {
 		return model.CheckResult{Status: model.PingStatusNotOk, Details: err.Error()}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Keys resolved by Load that don't appear in the final Config.
+const (
+	includeKey   = "include"
+	defaultsKey  = "defaults"
+	templatesKey = "templates"
+	extendsKey   = "extends"
+)
+
+// namedSections hold entries that each file owns as a whole: an entry defined
+// in two files is a conflict even if the definitions don't overlap.
+var namedSections = map[string]bool{
+	"backends":    true,
+	"templates":   true,
+	"query_sets":  true,
+	"datasources": true,
+}
+
+// composer builds one document from the main config file and its includes.
+type composer struct {
+	v      *validator
+	main   string
+	loaded map[string]bool
+	files  []string
+}
+
+func newComposer(v *validator, main string) *composer {
+	v.origins = make(map[string]string)
+	return &composer{v: v, main: main, loaded: make(map[string]bool)}
+}
+
+func readDocument(path string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// compose merges the includes into doc, then expands defaults and templates
+// into every backend.
+func (c *composer) compose(doc map[string]any) {
+	c.markLoaded(c.main)
+	includes := doc[includeKey]
+	delete(doc, includeKey)
+	c.include(doc, includes, c.main)
+	c.applyTemplates(doc)
+}
+
+func (c *composer) markLoaded(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if c.loaded[abs] {
+		return false
+	}
+	c.loaded[abs] = true
+	c.files = append(c.files, path)
+	return true
+}
+
+// include merges the files listed by the include key of from into doc. Paths
+// are relative to from; a directory means its *.yaml and *.yml files.
+func (c *composer) include(doc map[string]any, includes any, from string) {
+	if includes == nil {
+		return
+	}
+	file := c.displayName(from)
+	patterns, ok := stringList(includes)
+	if !ok {
+		c.v.reportIn(file, includeKey, "expected a file, glob or directory, or a list of them")
+		return
+	}
+
+	for i, pattern := range patterns {
+		path := includeKey
+		if _, isList := includes.([]any); isList {
+			path = fmt.Sprintf("%s[%d]", includeKey, i)
+		}
+		matches, err := expandInclude(from, pattern)
+		if err != nil {
+			c.v.reportIn(file, path, "%v", err)
+			continue
+		}
+		for _, match := range matches {
+			// Файл, подключённый дважды, например пересекающимися масками, читается один раз
+			if !c.markLoaded(match) {
+				continue
+			}
+			included, err := readDocument(match)
+			if err != nil {
+				c.v.reportIn(match, "", "%v", err)
+				continue
+			}
+			nested := included[includeKey]
+			delete(included, includeKey)
+			c.merge(doc, included, "", match)
+			c.include(doc, nested, match)
+		}
+	}
+}
+
+func expandInclude(from string, pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty include")
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		yaml, _ := filepath.Glob(filepath.Join(pattern, "*.yaml"))
+		yml, _ := filepath.Glob(filepath.Join(pattern, "*.yml"))
+		matches := append(yaml, yml...)
+		sort.Strings(matches)
+		return matches, nil
+	}
+
+	// Пустой conf.d не ошибка, а отсутствующий явно названный файл — ошибка
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob '%s': %w", pattern, err)
+		}
+		return matches, nil
+	}
+	if _, err := os.Stat(pattern); err != nil {
+		return nil, err
+	}
+	return []string{pattern}, nil
+}
+
+// merge adds src into dst. Objects are merged key by key; any other value,
+// and any entry of a named section, may be set by one file only.
+func (c *composer) merge(dst, src map[string]any, path string, file string) {
+	for _, key := range sortedKeys(src) {
+		value := src[key]
+		child := joinPath(path, key)
+
+		existing, ok := dst[key]
+		if !ok || existing == nil {
+			dst[key] = value
+			c.v.origins[child] = file
+			continue
+		}
+
+		dstMap, dstIsMap := existing.(map[string]any)
+		srcMap, srcIsMap := value.(map[string]any)
+		if dstIsMap && srcIsMap && !namedSections[path] {
+			c.merge(dstMap, srcMap, child, file)
+			continue
+		}
+		c.v.reportIn(file, child, "already defined in %s", c.fileOf(child))
+	}
+}
+
+func (c *composer) fileOf(path string) string {
+	if file := c.v.fileOf(path); file != "" {
+		return file
+	}
+	return c.main
+}
+
+func (c *composer) displayName(file string) string {
+	if file == c.main {
+		return ""
+	}
+	return file
+}
+
+// applyTemplates expands every backend into defaults, then its templates in
+// extends order, then its own fields. Later values win: objects such as
+// headers are merged key by key, other values and lists are replaced.
+func (c *composer) applyTemplates(doc map[string]any) {
+	defaults, defaultsOk := c.section(doc, defaultsKey)
+	templates, templatesOk := c.section(doc, templatesKey)
+	delete(doc, defaultsKey)
+	delete(doc, templatesKey)
+	if !defaultsOk || !templatesOk {
+		return
+	}
+
+	backends, _ := doc["backends"].(map[string]any)
+	resolved := make(map[string]map[string]any)
+	for _, name := range sortedKeys(backends) {
+		backend, ok := backends[name].(map[string]any)
+		if !ok {
+			// Неверный тип бэкенда покажет проверка схемы
+			continue
+		}
+		path := joinPath("backends", name)
+
+		merged := clone(defaults).(map[string]any)
+		for _, tpl := range c.extends(backend, path, templates, resolved, nil) {
+			overlay(merged, tpl)
+		}
+		own := clone(backend).(map[string]any)
+		delete(own, extendsKey)
+		overlay(merged, own)
+		backends[name] = merged
+	}
+}
+
+// section returns an optional top-level object, reporting other values.
+func (c *composer) section(doc map[string]any, key string) (map[string]any, bool) {
+	value, ok := doc[key]
+	if !ok || value == nil {
+		return map[string]any{}, true
+	}
+	m, ok := value.(map[string]any)
+	if !ok {
+		c.v.report(key, "expected object, got %s", describe(value))
+		return nil, false
+	}
+	return m, true
+}
+
+// extends returns the resolved templates named by the extends key of entry.
+// stack holds the templates being resolved to detect cycles.
+func (c *composer) extends(entry map[string]any, path string, templates map[string]any, resolved map[string]map[string]any, stack []string) []map[string]any {
+	raw, ok := entry[extendsKey]
+	if !ok || raw == nil {
+		return nil
+	}
+	names, ok := stringList(raw)
+	if !ok {
+		c.v.report(path+"."+extendsKey, "expected a template name or a list of them")
+		return nil
+	}
+
+	var result []map[string]any
+	for i, name := range names {
+		refPath := path + "." + extendsKey
+		if _, isList := raw.([]any); isList {
+			refPath = fmt.Sprintf("%s[%d]", refPath, i)
+		}
+		tpl, ok := c.template(name, refPath, templates, resolved, stack)
+		if ok {
+			result = append(result, tpl)
+		}
+	}
+	return result
+}
+
+func (c *composer) template(name string, refPath string, templates map[string]any, resolved map[string]map[string]any, stack []string) (map[string]any, bool) {
+	name = strings.ToLower(name)
+	if tpl, ok := resolved[name]; ok {
+		return tpl, true
+	}
+	for i, n := range stack {
+		if n == name {
+			cycle := append(append([]string(nil), stack[i:]...), name)
+			c.v.report(joinPath(templatesKey, stack[len(stack)-1])+"."+extendsKey, "template cycle: %s", strings.Join(cycle, " -> "))
+			return nil, false
+		}
+	}
+
+	raw, ok := templates[name]
+	if !ok {
+		c.v.report(refPath, "unknown template '%s'", name)
+		return nil, false
+	}
+	own, ok := raw.(map[string]any)
+	if !ok {
+		c.v.report(joinPath(templatesKey, name), "expected object, got %s", describe(raw))
+		return nil, false
+	}
+
+	merged := make(map[string]any)
+	for _, base := range c.extends(own, joinPath(templatesKey, name), templates, resolved, append(stack, name)) {
+		overlay(merged, base)
+	}
+	own = clone(own).(map[string]any)
+	delete(own, extendsKey)
+	overlay(merged, own)
+
+	resolved[name] = merged
+	return merged, true
+}
+
+// overlay writes src over dst, merging nested objects.
+func overlay(dst, src map[string]any) {
+	for key, value := range src {
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		srcMap, srcIsMap := value.(map[string]any)
+		if dstIsMap && srcIsMap {
+			overlay(dstMap, srcMap)
+			continue
+		}
+		dst[key] = clone(value)
+	}
+}
+
+func clone(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(value))
+		for k, v := range value {
+			out[k] = clone(v)
+		}
+		return out
+	case []any:
+		out := make([]any, len(value))
+		for i, v := range value {
+			out[i] = clone(v)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// stringList accepts a single string or a list of strings.
+func stringList(value any) ([]string, bool) {
+	switch value := value.(type) {
+	case string:
+		return []string{value}, true
+	case []any:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+		return list, true
+	default:
+		return nil, false
+	}
+}
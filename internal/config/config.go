@@ -1,19 +1,39 @@
 package config
 
 import (
-	"os"
+	"encoding/base64"
+	"fmt"
 	"strings"
 	"time"
 
-	validatorPkg "github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Backends   map[string]BackendConfig `yaml:"backends" mapstructure:"backends"`
-	Prometheus PrometheusConfig         `yaml:"prometheus" mapstructure:"prometheus"`
+	// Name identifies this pingr instance.
+	Name       string                    `yaml:"name" mapstructure:"name"`
+	Backends   map[string]BackendConfig  `yaml:"backends" mapstructure:"backends"`
+	Prometheus PrometheusConfig          `yaml:"prometheus" mapstructure:"prometheus"`
+	QuerySets  map[string]QuerySetConfig `yaml:"query_sets" mapstructure:"query_sets"`
+	// Datasources are additional named Prometheus-compatible servers.
+	// The prometheus block, if set, is available as DefaultDatasource.
+	Datasources  map[string]PrometheusConfig `yaml:"datasources" mapstructure:"datasources"`
+	Loki         LokiConfig                  `yaml:"loki" mapstructure:"loki"`
+	LLM          LLMConfig                   `yaml:"llm" mapstructure:"llm"`
+	Alert        AlertConfig                 `yaml:"alert" mapstructure:"alert"`
+	Redaction    RedactionConfig             `yaml:"redaction" mapstructure:"redaction"`
+	History      HistoryConfig               `yaml:"history" mapstructure:"history"`
+	Telemetry    TelemetryConfig             `yaml:"telemetry" mapstructure:"telemetry"`
+	Infographics InfographicsConfig          `yaml:"infographics" mapstructure:"infographics"`
+	Telegram     TelegramConfig              `yaml:"telegram" mapstructure:"telegram"`
+
+	// Files are the config files Load read, the main one first.
+	Files []string `yaml:"-" mapstructure:"-"`
 }
 
+// DefaultDatasource is the name of the datasource described by the prometheus block.
+const DefaultDatasource = "default"
+
 type BackendConfig struct {
 	Type           string            `yaml:"type" mapstructure:"type"`
 	Deps           []string          `yaml:"deps" mapstructure:"deps"`
@@ -23,31 +43,315 @@ type BackendConfig struct {
 	Port           int               `yaml:"port" mapstructure:"port"`
 	Headers        map[string]string `yaml:"headers" mapstructure:"headers"`
 	MetricsQueries []string          `yaml:"metrics_queries" mapstructure:"metrics_queries"`
+	QuerySets      []string          `yaml:"query_sets" mapstructure:"query_sets"`
+	Labels         map[string]string `yaml:"labels" mapstructure:"labels"`
+	Datasource     string            `yaml:"datasource" mapstructure:"datasource"`
+	// LogQuery is a LogQL template rendered like metrics queries, e.g. `{job="{{.Backend}}"} |= "error"`.
+	LogQuery string `yaml:"log_query" mapstructure:"log_query"`
+
+	// Context for the RCA prompt: what the service does, who owns it and how it usually fails.
+	Description string `yaml:"description" mapstructure:"description"`
+	Owner       string `yaml:"owner" mapstructure:"owner"`
+	RunbookURL  string `yaml:"runbook_url" mapstructure:"runbook_url"`
+	Notes       string `yaml:"notes" mapstructure:"notes"`
+	// DashboardURL is linked from the SVG and HTML infographics when there is no runbook.
+	DashboardURL string `yaml:"dashboard_url" mapstructure:"dashboard_url"`
+}
+
+// QuerySetConfig is a named group of metrics queries shared by several backends.
+// Queries are Go templates rendered per backend, e.g. `up{job="{{.Backend}}"}`.
+// Datasource overrides the backend's datasource for the queries of this set.
+type QuerySetConfig struct {
+	Queries    []string `yaml:"queries" mapstructure:"queries"`
+	Datasource string   `yaml:"datasource" mapstructure:"datasource"`
+}
+
+// MetricsQuery is a metrics query of a backend with the datasource it runs on.
+type MetricsQuery struct {
+	Query      string
+	Datasource string
+}
+
+// MetricsQueriesFor returns the queries of all query sets referenced by the
+// backend followed by its own metrics_queries. A query runs on the datasource
+// of its query set, then of the backend, then on DefaultDatasource; the same
+// query on the same datasource is returned once. Queries are not rendered yet.
+func (c Config) MetricsQueriesFor(backend string) []MetricsQuery {
+	bc, ok := c.Backends[backend]
+	if !ok {
+		return nil
+	}
+	datasource := bc.Datasource
+	if datasource == "" {
+		datasource = DefaultDatasource
+	}
+
+	var queries []MetricsQuery
+	seen := make(map[MetricsQuery]bool)
+	add := func(query string, datasource string) {
+		q := MetricsQuery{Query: query, Datasource: datasource}
+		if !seen[q] {
+			seen[q] = true
+			queries = append(queries, q)
+		}
+	}
+	for _, name := range bc.QuerySets {
+		set := c.QuerySets[name]
+		setDatasource := datasource
+		if set.Datasource != "" {
+			setDatasource = set.Datasource
+		}
+		for _, query := range set.Queries {
+			add(query, setDatasource)
+		}
+	}
+	for _, query := range bc.MetricsQueries {
+		add(query, datasource)
+	}
+	return queries
+}
+
+// AllDatasources returns all configured datasources by name, including the
+// prometheus block as DefaultDatasource when its URL is set.
+func (c Config) AllDatasources() map[string]PrometheusConfig {
+	all := make(map[string]PrometheusConfig, len(c.Datasources)+1)
+	if c.Prometheus.URL != "" {
+		all[DefaultDatasource] = c.Prometheus
+	}
+	for name, ds := range c.Datasources {
+		all[name] = ds
+	}
+	return all
 }
 
 type PrometheusConfig struct {
+	URL      string            `yaml:"url" mapstructure:"url"`
+	Timeout  time.Duration     `yaml:"timeout" mapstructure:"timeout"`
+	Headers  map[string]string `yaml:"headers" mapstructure:"headers"`
+	Baseline BaselineConfig    `yaml:"baseline" mapstructure:"baseline"`
+	// Sparkline adds recent history of every metric for the infographics.
+	Sparkline SparklineConfig `yaml:"sparkline" mapstructure:"sparkline"`
+	// QueryTimeout bounds a single query; zero means only Timeout applies.
+	QueryTimeout time.Duration `yaml:"query_timeout" mapstructure:"query_timeout"`
+	// Concurrency limits parallel queries per backend, 4 by default.
+	Concurrency int `yaml:"concurrency" mapstructure:"concurrency"`
+	// CacheTTL lets alerts within the window reuse query results; zero disables the cache.
+	CacheTTL time.Duration `yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	// Type is informational: prometheus or victoriametrics, both speak the same query API.
+	Type string     `yaml:"type" mapstructure:"type"`
+	Auth AuthConfig `yaml:"auth" mapstructure:"auth"`
+}
+
+// LokiConfig enables log excerpts for failing backends. An empty URL disables it.
+type LokiConfig struct {
 	URL     string            `yaml:"url" mapstructure:"url"`
 	Timeout time.Duration     `yaml:"timeout" mapstructure:"timeout"`
 	Headers map[string]string `yaml:"headers" mapstructure:"headers"`
+	Auth    AuthConfig        `yaml:"auth" mapstructure:"auth"`
+	// Limit is the number of most recent lines per backend, 20 by default.
+	Limit int `yaml:"limit" mapstructure:"limit"`
+	// Lookback is how far back lines are searched, 15m by default.
+	Lookback time.Duration `yaml:"lookback" mapstructure:"lookback"`
+}
+
+// LLMConfig describes an OpenAI-compatible chat completions endpoint.
+// Without BaseURL the generator falls back to Yandex Cloud in Folder.
+type LLMConfig struct {
+	BaseURL string `yaml:"base_url" mapstructure:"base_url"`
+	Model   string `yaml:"model" mapstructure:"model"`
+	// Folder is the Yandex Cloud folder ID, used only without BaseURL.
+	Folder string `yaml:"folder" mapstructure:"folder"`
+	// APIKey is usually a reference such as ${LLM_API_KEY} or ${file:/run/secrets/llm_key}.
+	APIKey      string            `yaml:"api_key" mapstructure:"api_key"`
+	Headers     map[string]string `yaml:"headers" mapstructure:"headers"`
+	Temperature *float64          `yaml:"temperature" mapstructure:"temperature"`
+	MaxTokens   int64             `yaml:"max_tokens" mapstructure:"max_tokens"`
+	// Timeout bounds the whole generation including retries, 60s by default.
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+	// MaxRetries is the number of retries with backoff on connection errors,
+	// 429 and 5xx responses, 2 by default.
+	MaxRetries *int `yaml:"max_retries" mapstructure:"max_retries"`
+	// CacheTTL reuses the analysis of an incident with the same fingerprint
+	// for this long; zero disables caching.
+	CacheTTL time.Duration `yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	// ResponseFormat is one of the LLMResponseFormat* values; the default is
+	// LLMResponseFormatJSONSchema.
+	ResponseFormat string `yaml:"response_format" mapstructure:"response_format"`
+	// PromptTemplate is an inline Go text/template for the RCA prompt executed
+	// with generator.PromptData; PromptTemplateFile takes precedence.
+	PromptTemplate     string `yaml:"prompt_template" mapstructure:"prompt_template"`
+	PromptTemplateFile string `yaml:"prompt_template_file" mapstructure:"prompt_template_file"`
+}
+
+const (
+	// The model returns a JSON analysis constrained by a JSON schema
+	LLMResponseFormatJSONSchema = "json_schema"
+	// JSON mode without a schema, for endpoints that don't support schemas
+	LLMResponseFormatJSONObject = "json_object"
+	// Free text, passed through as is without a structured analysis
+	LLMResponseFormatText = "text"
+)
+
+const (
+	AlertGeneratorLLM             = "llm"
+	AlertGeneratorTemplate        = "template"
+	AlertGeneratorLLMWithFallback = "llm_with_fallback"
+)
+
+// AlertConfig chooses how alert messages are generated. The default is
+// AlertGeneratorLLMWithFallback, so an LLM outage never suppresses alerts.
+type AlertConfig struct {
+	Generator string `yaml:"generator" mapstructure:"generator"`
+	// Template is an inline Go text/template; TemplateFile takes precedence.
+	Template     string `yaml:"template" mapstructure:"template"`
+	TemplateFile string `yaml:"template_file" mapstructure:"template_file"`
+}
+
+// RedactionConfig controls scrubbing of secrets from prompts and alert messages.
+// Credentials found in this config are always masked.
+type RedactionConfig struct {
+	// Patterns are extra regular expressions whose matches are masked.
+	Patterns []string `yaml:"patterns" mapstructure:"patterns"`
+	// DisableDefaults turns off the built-in URL credential, token and password patterns.
+	DisableDefaults bool `yaml:"disable_defaults" mapstructure:"disable_defaults"`
+}
+
+// HistoryConfig bounds the check history kept in memory for the RCA timeline.
+type HistoryConfig struct {
+	// Size is the number of status transitions kept per backend, 20 by default.
+	Size int `yaml:"size" mapstructure:"size"`
+}
+
+const (
+	// Graphviz when the dot binary is installed, the native renderer otherwise
+	InfographicsRendererAuto     = "auto"
+	InfographicsRendererNative   = "native"
+	InfographicsRendererGraphviz = "graphviz"
+)
+
+// InfographicsConfig selects how the dependency graph picture is drawn.
+type InfographicsConfig struct {
+	// Disabled sends alerts as text only.
+	Disabled bool `yaml:"disabled" mapstructure:"disabled"`
+	// Renderer is one of the InfographicsRenderer* values, auto by default.
+	Renderer string `yaml:"renderer" mapstructure:"renderer"`
+}
+
+// TelegramConfig is where alerts are sent. APIURL defaults to the public
+// Bot API; Token is usually a reference such as ${TG_TOKEN}.
+type TelegramConfig struct {
+	APIURL string `yaml:"api_url" mapstructure:"api_url"`
+	Token  string `yaml:"token" mapstructure:"token"`
+	ChatID string `yaml:"chat_id" mapstructure:"chat_id"`
+}
+
+// TelemetryConfig exposes pingr's own Prometheus metrics, e.g. LLM latency
+// and token usage. The endpoint is disabled when ListenAddr is empty.
+type TelemetryConfig struct {
+	ListenAddr string `yaml:"listen_addr" mapstructure:"listen_addr"`
+}
+
+// AuthConfig configures either basic or bearer token authentication.
+type AuthConfig struct {
+	Username    string `yaml:"username" mapstructure:"username"`
+	Password    string `yaml:"password" mapstructure:"password"`
+	BearerToken string `yaml:"bearer_token" mapstructure:"bearer_token"`
+}
+
+// AuthorizationHeader returns the Authorization header value, or an empty
+// string when no authentication is configured.
+func (a AuthConfig) AuthorizationHeader() string {
+	switch {
+	case a.BearerToken != "":
+		return "Bearer " + a.BearerToken
+	case a.Username != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password))
+	default:
+		return ""
+	}
+}
+
+const (
+	// BaselineModeStdDev compares the current value with mean/stddev over the lookback window.
+	BaselineModeStdDev = "stddev"
+	// BaselineModeLastWeek compares the current value with the value at the same time a week ago.
+	BaselineModeLastWeek = "last_week"
+)
+
+// BaselineConfig enables anomaly detection for extracted metrics.
+// An empty Mode disables it and only instant queries are made.
+type BaselineConfig struct {
+	Mode      string        `yaml:"mode" mapstructure:"mode"`
+	Lookback  time.Duration `yaml:"lookback" mapstructure:"lookback"`
+	Step      time.Duration `yaml:"step" mapstructure:"step"`
+	Threshold float64       `yaml:"threshold" mapstructure:"threshold"`
+}
+
+// SparklineConfig enables a range query over the last Window for every
+// metrics query. A zero Window disables it; Step defaults to Window/30.
+type SparklineConfig struct {
+	Window time.Duration `yaml:"window" mapstructure:"window"`
+	Step   time.Duration `yaml:"step" mapstructure:"step"`
 }
 
+// Load reads the YAML config with its includes, expands defaults, templates
+// and ${...} references, and validates the result against the config schema.
+// All problems are reported at once as a *ValidationError.
 func Load(configPath string) (*Config, error) {
-	viper.SetConfigFile(configPath)
+	config, _, err := LoadWith(configPath, LoadOptions{})
+	return config, err
+}
 
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+// LoadOptions change how LoadWith treats a config.
+type LoadOptions struct {
+	// AllowUnresolved turns env and file references that can't be resolved
+	// into warnings and leaves their values unset, e.g. to lint a config in
+	// CI without production secrets.
+	AllowUnresolved bool
+}
+
+// LoadWith is Load with options. Warnings are returned even for a valid config.
+func LoadWith(configPath string, opts LoadOptions) (*Config, []Problem, error) {
+	doc, err := readDocument(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	viper.SetDefault("logger.instance", os.Getenv("HOSTNAME"))
-	viper.SetConfigType("yaml")
+	val := &validator{allowUnresolved: opts.AllowUnresolved, unresolved: make(map[string]bool)}
+	c := newComposer(val, configPath)
+	c.compose(doc)
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, err
+	// Переменные окружения вида BACKENDS_API_URL переопределяют уже собранные значения
+	env := viper.New()
+	env.AutomaticEnv()
+	env.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if err := env.MergeConfigMap(doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	doc = val.interpolate(env.AllSettings(), "").(map[string]any)
+	normalizeDocument(doc)
+	val.checkSchema(configSchema(), doc, "")
+
+	// Декодируем уже подставленные значения, а не исходный файл
+	resolved := viper.New()
+	if err := resolved.MergeConfigMap(doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode config: %w", err)
 	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, err
+	if err := resolved.Unmarshal(&config); err != nil {
+		// Ошибки схемы понятнее ошибок декодера
+		if verr := val.err(); verr != nil {
+			return nil, val.warnings, verr
+		}
+		return nil, val.warnings, fmt.Errorf("failed to decode config: %w", err)
 	}
+	config.Files = c.files
 
-	return &config, validatorPkg.New().Struct(&config)
+	val.checkReferences(&config)
+	if err := val.err(); err != nil {
+		return nil, val.warnings, err
+	}
+	return &config, val.warnings, nil
 }
@@ -32,7 +32,7 @@ backends:
       Authorization: "Bearer mytoken"
       X-Feature: "test"
   local:
-    type: local
+    type: icmp
     deps: ["myapi"]
     url: "file:///tmp/local"
     timeout: 0
@@ -62,7 +62,7 @@ backends:
 	}, api.Headers)
 
 	local := cfg.Backends["local"]
-	assert.Equal(t, "local", local.Type)
+	assert.Equal(t, "icmp", local.Type)
 	assert.Equal(t, []string{"myapi"}, local.Deps)
 	assert.Equal(t, "file:///tmp/local", local.URL)
 	assert.Equal(t, time.Duration(0), local.Timeout)
@@ -76,4 +76,462 @@ func TestLoadConfig_MissingFile(t *testing.T) {
 	assert.Error(t, err)
 	assert.True(t, strings.Contains(err.Error(), "no such file"))
 
-}
\ No newline at end of file
+}
+func TestConfig_MetricsQueriesFor(t *testing.T) {
+	cfg := config.Config{
+		QuerySets: map[string]config.QuerySetConfig{
+			"base":    {Queries: []string{`up{job="{{.Backend}}"}`}},
+			"history": {Queries: []string{"errors", `up{job="{{.Backend}}"}`}, Datasource: "vm"},
+			"rates":   {Queries: []string{"errors"}, Datasource: "vm"},
+		},
+		Backends: map[string]config.BackendConfig{
+			"api":   {QuerySets: []string{"base"}, MetricsQueries: []string{"extra"}},
+			"eu":    {Datasource: "eu", QuerySets: []string{"history", "rates", "base"}, MetricsQueries: []string{"errors", "extra"}},
+			"plain": {},
+		},
+	}
+
+	assert.Equal(t, []config.MetricsQuery{
+		{Query: `up{job="{{.Backend}}"}`, Datasource: config.DefaultDatasource},
+		{Query: "extra", Datasource: config.DefaultDatasource},
+	}, cfg.MetricsQueriesFor("api"))
+	// Запрос идёт в источник своего набора, даже если такой же текст есть
+	// в другом наборе или в metrics_queries; повтор в том же источнике выполняется один раз
+	assert.Equal(t, []config.MetricsQuery{
+		{Query: "errors", Datasource: "vm"},
+		{Query: `up{job="{{.Backend}}"}`, Datasource: "vm"},
+		{Query: `up{job="{{.Backend}}"}`, Datasource: "eu"},
+		{Query: "errors", Datasource: "eu"},
+		{Query: "extra", Datasource: "eu"},
+	}, cfg.MetricsQueriesFor("eu"))
+	assert.Nil(t, cfg.MetricsQueriesFor("plain"))
+	assert.Nil(t, cfg.MetricsQueriesFor("missing"))
+}
+
+func TestLoadConfig_BackendTypeCaseInsensitive(t *testing.T) {
+	yaml := `
+backends:
+  api:
+    type: HTTP
+    url: http://api/health
+  cache:
+    type: Redis
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml), 0644))
+
+	// Схема и чекер одинаково не различают регистр типа
+	_, err := config.Load(configPath)
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []config.Problem{
+		{Path: "backends.cache.host", Message: "required for type redis"},
+		{Path: "backends.cache.port", Message: "required for type redis"},
+	}, verr.Problems)
+
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml+"    host: cache\n    port: 6379\n"), 0644))
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, config.BackendTypeHTTP, cfg.Backends["api"].Type)
+
+	assert.NoError(t, config.ValidateConfig(&config.Config{
+		Backends: map[string]config.BackendConfig{"api": {Type: "HTTP", URL: "http://api"}},
+	}))
+}
+
+func TestValidateConfig_UnknownQuerySet(t *testing.T) {
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {Type: "http", URL: "http://api", QuerySets: []string{"nope"}},
+		},
+	}
+
+	err := config.ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown query set 'nope'")
+}
+
+func TestValidateConfig_UnknownDatasource(t *testing.T) {
+	cfg := &config.Config{
+		Prometheus: config.PrometheusConfig{URL: "http://prometheus:9090"},
+		Datasources: map[string]config.PrometheusConfig{
+			"vm": {URL: "http://vm:8428", Type: "victoriametrics"},
+		},
+		Backends: map[string]config.BackendConfig{
+			"api": {Type: "http", URL: "http://api", Datasource: "eu"},
+		},
+	}
+
+	err := config.ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown datasource 'eu'")
+
+	cfg.Backends["api"] = config.BackendConfig{Type: "http", URL: "http://api", Datasource: "vm"}
+	assert.NoError(t, config.ValidateConfig(cfg))
+}
+
+func TestValidateConfig_UnknownDependency(t *testing.T) {
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {Type: "http", URL: "http://api", Deps: []string{"db"}},
+		},
+	}
+
+	err := config.ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.EqualError(t, err, "backends.api.deps[0]: unknown dependency 'db'")
+
+	cfg.Backends["db"] = config.BackendConfig{Type: "postgres", URL: "postgres://db"}
+	assert.NoError(t, config.ValidateConfig(cfg))
+}
+
+func TestValidateConfig_DependencyCycle(t *testing.T) {
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"front": {Type: "http", URL: "http://front", Deps: []string{"api"}},
+			"api":   {Type: "http", URL: "http://api", Deps: []string{"cache", "db"}},
+			"cache": {Type: "redis", Host: "cache", Port: 6379},
+			"db":    {Type: "postgres", URL: "postgres://db", Deps: []string{"queue"}},
+			"queue": {Type: "tcp", Host: "queue", Port: 5672, Deps: []string{"api"}},
+		},
+	}
+
+	err := config.ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.EqualError(t, err, "backends.api.deps: dependency cycle: api -> db -> queue -> api")
+}
+
+func TestValidateConfig_SelfDependency(t *testing.T) {
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {Type: "http", URL: "http://api", Deps: []string{"api"}},
+		},
+	}
+
+	err := config.ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.EqualError(t, err, "backends.api.deps: dependency cycle: api -> api")
+}
+
+func TestLoadConfig_ReportsAllProblems(t *testing.T) {
+	yaml := `
+backends:
+  api:
+    type: http
+    url: http://api
+    timeout: soon
+    retries: 3
+  rpc:
+    type: grpc
+    url: grpc://rpc:9090
+  cache:
+    type: memcached
+    deps: ["api", "db"]
+alert:
+  generator: magic
+`
+	configPath := filepath.Join(t.TempDir(), "bad.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml), 0644))
+
+	cfg, err := config.Load(configPath)
+	require.Error(t, err)
+	assert.Nil(t, cfg)
+
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []config.Problem{
+		{Path: "alert.generator", Message: "unknown value 'magic', expected one of: llm, template, llm_with_fallback"},
+		{Path: "backends.api.retries", Message: "unknown key"},
+		{Path: "backends.api.timeout", Message: "invalid value 'soon'"},
+		{Path: "backends.cache.type", Message: "unknown value 'memcached', expected one of: grpc, http, icmp, postgres, redis, tcp"},
+		{Path: "backends.rpc.host", Message: "required for type grpc"},
+		{Path: "backends.rpc.port", Message: "required for type grpc"},
+	}, verr.Problems)
+	assert.True(t, strings.HasPrefix(err.Error(), "6 config problems:\n  alert.generator: "))
+}
+
+func TestValidateConfig_ReportsAllProblems(t *testing.T) {
+	cfg := &config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api":  {Type: "http", QuerySets: []string{"nope"}, Deps: []string{"db"}},
+			"ping": {Type: "icmp", Host: "10.0.0.1"},
+		},
+	}
+
+	err := config.ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.Equal(t, "3 config problems:\n"+
+		"  backends.api.deps[0]: unknown dependency 'db'\n"+
+		"  backends.api.query_sets[0]: unknown query set 'nope'\n"+
+		"  backends.api.url: required for type http", err.Error())
+}
+
+func TestJSONSchema_UpToDate(t *testing.T) {
+	want, err := config.JSONSchema()
+	require.NoError(t, err)
+
+	got, err := os.ReadFile("../../config/config.schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "config/config.schema.json is stale, run make schema")
+}
+
+func TestLoadConfig_Interpolation(t *testing.T) {
+	t.Setenv("PINGR_TEST_TOKEN", "tg-secret")
+	t.Setenv("PINGR_TEST_PORT", "6380")
+	t.Setenv("PINGR_TEST_EMPTY", "")
+
+	secret := filepath.Join(t.TempDir(), "llm_key")
+	require.NoError(t, os.WriteFile(secret, []byte("llm-secret\n"), 0600))
+
+	yaml := `
+telegram:
+  api_url: ${PINGR_TEST_UNSET:-https://api.telegram.org}
+  token: ${PINGR_TEST_TOKEN}
+  chat_id: ${PINGR_TEST_EMPTY:--100}
+llm:
+  api_key: ${file:` + secret + `}
+backends:
+  cache:
+    type: redis
+    host: cache
+    port: ${PINGR_TEST_PORT}
+    timeout: ${PINGR_TEST_UNSET:-3s}
+    headers:
+      Authorization: "Bearer ${PINGR_TEST_TOKEN}"
+    log_query: '{job="cache"} |= "$${literal}"'
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml), 0644))
+
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, config.TelegramConfig{
+		APIURL: "https://api.telegram.org",
+		Token:  "tg-secret",
+		ChatID: "-100",
+	}, cfg.Telegram)
+	assert.Equal(t, "llm-secret", cfg.LLM.APIKey)
+
+	cache := cfg.Backends["cache"]
+	assert.Equal(t, 6380, cache.Port)
+	assert.Equal(t, 3*time.Second, cache.Timeout)
+	assert.Equal(t, "Bearer tg-secret", cache.Headers["authorization"])
+	assert.Equal(t, `{job="cache"} |= "${literal}"`, cache.LogQuery)
+}
+
+func TestLoadConfig_InterpolationErrors(t *testing.T) {
+	yaml := `
+telegram:
+  token: ${PINGR_TEST_UNSET}
+llm:
+  api_key: ${file:/does/not/exist}
+backends:
+  api:
+    type: http
+    url: "http://${PINGR_TEST_HOST"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml), 0644))
+
+	_, err := config.Load(configPath)
+
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Problems, 3)
+	assert.Equal(t, config.Problem{Path: "backends.api.url", Message: "unterminated reference '${PINGR_TEST_HOST'"}, verr.Problems[0])
+	assert.Equal(t, "llm.api_key", verr.Problems[1].Path)
+	assert.Contains(t, verr.Problems[1].Message, "read '${file:/does/not/exist}'")
+	assert.Equal(t, config.Problem{Path: "telegram.token", Message: "environment variable PINGR_TEST_UNSET is not set"}, verr.Problems[2])
+}
+
+func TestLoadWith_AllowUnresolved(t *testing.T) {
+	yaml := `
+telegram:
+  token: ${PINGR_TEST_UNSET}
+backends:
+  api:
+    type: http
+    url: ${PINGR_TEST_UNSET_URL}
+  cache:
+    type: redis
+    host: cache
+    port: ${file:/does/not/exist}
+  web:
+    type: http
+    url: "http://${PINGR_TEST_HOST"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml), 0644))
+
+	// Неразрешённые ссылки не дают ни ошибки, ни "required"; синтаксическая ошибка остаётся ошибкой
+	_, warnings, err := config.LoadWith(configPath, config.LoadOptions{AllowUnresolved: true})
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []config.Problem{{Path: "backends.web.url", Message: "unterminated reference '${PINGR_TEST_HOST'"}}, verr.Problems)
+	require.Len(t, warnings, 3)
+	assert.Equal(t, config.Problem{Path: "backends.api.url", Message: "environment variable PINGR_TEST_UNSET_URL is not set"}, warnings[0])
+	assert.Equal(t, "backends.cache.port", warnings[1].Path)
+	assert.Equal(t, "telegram.token", warnings[2].Path)
+}
+
+func TestLoadConfig_RemovedLLMKeys(t *testing.T) {
+	yaml := `
+llm:
+  api_key_env: LLM_API_KEY
+  api_key_file: /run/secrets/llm_api_key
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(yaml), 0644))
+
+	_, err := config.Load(configPath)
+
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []config.Problem{
+		{Path: "llm.api_key_env", Message: "no longer supported, use api_key: ${VAR} instead"},
+		{Path: "llm.api_key_file", Message: "no longer supported, use api_key: ${file:/path} instead"},
+	}, verr.Problems)
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoadConfig_IncludesDefaultsAndTemplates(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+include: conf.d
+defaults:
+  timeout: 5s
+  headers:
+    X-Team: sre
+templates:
+  web:
+    type: http
+    headers:
+      Accept: application/json
+    metrics_queries: ["up"]
+  web_slow:
+    extends: web
+    timeout: 30s
+backends:
+  front:
+    extends: web
+    url: http://front
+`,
+		"conf.d/payments.yaml": `
+backends:
+  payments:
+    extends: web_slow
+    url: http://payments
+    deps: ["front"]
+    headers:
+      X-Team: payments
+`,
+		"conf.d/search.yml": `
+include: ../shared/*.yaml
+backends:
+  search:
+    extends: [web]
+    url: http://search
+    metrics_queries: ["search_latency"]
+`,
+		"shared/cache.yaml": `
+backends:
+  cache:
+    type: redis
+    host: cache
+    port: 6379
+`,
+	})
+
+	cfg, err := config.Load(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.BackendConfig{
+		Type:           "http",
+		URL:            "http://front",
+		Timeout:        5 * time.Second,
+		Headers:        map[string]string{"x-team": "sre", "accept": "application/json"},
+		MetricsQueries: []string{"up"},
+	}, cfg.Backends["front"])
+
+	// Поля бэкенда важнее шаблонов, шаблоны важнее defaults, карты сливаются
+	assert.Equal(t, config.BackendConfig{
+		Type:           "http",
+		URL:            "http://payments",
+		Deps:           []string{"front"},
+		Timeout:        30 * time.Second,
+		Headers:        map[string]string{"x-team": "payments", "accept": "application/json"},
+		MetricsQueries: []string{"up"},
+	}, cfg.Backends["payments"])
+
+	// Списки заменяются целиком
+	assert.Equal(t, []string{"search_latency"}, cfg.Backends["search"].MetricsQueries)
+
+	assert.Equal(t, "redis", cfg.Backends["cache"].Type)
+	assert.Equal(t, 5*time.Second, cfg.Backends["cache"].Timeout)
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "config.yaml"),
+		filepath.Join(dir, "conf.d/payments.yaml"),
+		filepath.Join(dir, "conf.d/search.yml"),
+		filepath.Join(dir, "shared/cache.yaml"),
+	}, cfg.Files)
+}
+
+func TestLoadConfig_IncludeProblems(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+include: ["conf.d/*.yaml", "missing.yaml"]
+prometheus:
+  url: http://prometheus:9090
+templates:
+  a:
+    extends: b
+  b:
+    extends: a
+backends:
+  api:
+    type: http
+    url: http://api
+`,
+		"conf.d/team.yaml": `
+prometheus:
+  url: http://other:9090
+  timeout: 5s
+backends:
+  api:
+    type: http
+    url: http://api
+  worker:
+    extends: [a, nope]
+    type: tcp
+    host: worker
+`,
+	})
+
+	_, err := config.Load(filepath.Join(dir, "config.yaml"))
+
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	team := filepath.Join(dir, "conf.d/team.yaml")
+	require.Len(t, verr.Problems, 6)
+	assert.Equal(t, "include[1]", verr.Problems[0].Path)
+	assert.Contains(t, verr.Problems[0].Message, "no such file")
+	assert.Equal(t, []config.Problem{
+		{Path: "templates.b.extends", Message: "template cycle: a -> b -> a"},
+		{File: team, Path: "backends.api", Message: "already defined in " + filepath.Join(dir, "config.yaml")},
+		{File: team, Path: "backends.worker.extends[1]", Message: "unknown template 'nope'"},
+		{File: team, Path: "backends.worker.port", Message: "required for type tcp"},
+		{File: team, Path: "prometheus.url", Message: "already defined in " + filepath.Join(dir, "config.yaml")},
+	}, verr.Problems[1:])
+}
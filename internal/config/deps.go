@@ -0,0 +1,48 @@
+package config
+
+// findDependencyCycle walks the graph depth-first in name order and returns the
+// first cycle found, starting and ending with the same backend.
+func findDependencyCycle(backends map[string]BackendConfig, names []string) []string {
+	const (
+		unvisited = iota
+		inStack
+		done
+	)
+	state := make(map[string]int, len(backends))
+	var stack []string
+
+	var visit func(string) []string
+	visit = func(name string) []string {
+		state[name] = inStack
+		stack = append(stack, name)
+		for _, dep := range backends[name].Deps {
+			switch state[dep] {
+			case inStack:
+				for i, n := range stack {
+					if n == dep {
+						return append(append([]string(nil), stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if _, ok := backends[dep]; !ok {
+					continue
+				}
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
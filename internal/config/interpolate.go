@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// filePrefix marks a reference to a file, e.g. ${file:/run/secrets/tg_token}.
+const filePrefix = "file:"
+
+// interpolate expands references in every string of a decoded config document:
+//
+//	${VAR}            the environment variable, which must be set
+//	${VAR:-default}   the variable, or default when it is unset or empty
+//	${file:/path}     the file contents without the trailing newline
+//	$${               a literal ${
+//
+// Problems are reported at the YAML path of the value. With allowUnresolved a
+// variable that is not set or a file that can't be read is a warning and the
+// value is left unset.
+func (v *validator) interpolate(value any, path string) any {
+	switch value := value.(type) {
+	case string:
+		expanded, err := expand(value)
+		var unresolved unresolvedError
+		if err != nil && v.allowUnresolved && errors.As(err, &unresolved) {
+			v.warn(path, "%v", err)
+			v.unresolved[path] = true
+			return nil
+		}
+		if err != nil {
+			v.report(path, "%v", err)
+			return value
+		}
+		return expanded
+	case map[string]any:
+		out := make(map[string]any, len(value))
+		for key, item := range value {
+			out[key] = v.interpolate(item, joinPath(path, key))
+		}
+		return out
+	case []any:
+		out := make([]any, len(value))
+		for i, item := range value {
+			out[i] = v.interpolate(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+func expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		// $${ экранирует подстановку
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference '%s'", s[i:])
+		}
+		resolved, err := resolve(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(resolved)
+		s = s[i+end+1:]
+	}
+}
+
+// unresolvedError is a well-formed reference to a variable or a file that is
+// not available, as opposed to a malformed reference.
+type unresolvedError struct {
+	error
+}
+
+func resolve(ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, filePrefix); ok {
+		if path == "" {
+			return "", fmt.Errorf("empty file reference '${%s}'", ref)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", unresolvedError{fmt.Errorf("read '${%s}': %w", ref, err)}
+		}
+		// Секреты обычно записаны с переводом строки в конце
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, fallback, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("empty variable name in '${%s}'", ref)
+	}
+	value, ok := os.LookupEnv(name)
+	if hasDefault && value == "" {
+		return fallback, nil
+	}
+	if !ok {
+		return "", unresolvedError{fmt.Errorf("environment variable %s is not set", name)}
+	}
+	return value, nil
+}
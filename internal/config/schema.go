@@ -0,0 +1,206 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Schema is the subset of JSON Schema used to describe the config. The same
+// schema drives validation and is exported for editor autocompletion.
+type Schema struct {
+	SchemaURI   string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        schemaType         `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is false for structs and the value schema for maps.
+	AdditionalProperties any       `json:"additionalProperties,omitempty"`
+	Required             []string  `json:"required,omitempty"`
+	Items                *Schema   `json:"items,omitempty"`
+	Enum                 []string  `json:"enum,omitempty"`
+	Const                string    `json:"const,omitempty"`
+	Pattern              string    `json:"pattern,omitempty"`
+	AllOf                []*Schema `json:"allOf,omitempty"`
+	If                   *Schema   `json:"if,omitempty"`
+	Then                 *Schema   `json:"then,omitempty"`
+}
+
+// schemaType is a JSON Schema type, written as a string when there is one.
+type schemaType []string
+
+func (t schemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+const (
+	schemaObject  = "object"
+	schemaArray   = "array"
+	schemaString  = "string"
+	schemaInteger = "integer"
+	schemaNumber  = "number"
+	schemaBoolean = "boolean"
+)
+
+// Длительность задаётся строкой вида 1m30s или целым числом наносекунд
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Backend types supported by the checker.
+const (
+	BackendTypeHTTP     = "http"
+	BackendTypeGRPC     = "grpc"
+	BackendTypeICMP     = "icmp"
+	BackendTypeTCP      = "tcp"
+	BackendTypeRedis    = "redis"
+	BackendTypePostgres = "postgres"
+)
+
+// backendRequired lists the fields every backend type needs, in the order the
+// checker uses them.
+var backendRequired = map[string][]string{
+	BackendTypeHTTP:     {"url"},
+	BackendTypeGRPC:     {"host", "port"},
+	BackendTypeICMP:     {"host"},
+	BackendTypeTCP:      {"host", "port"},
+	BackendTypeRedis:    {"host", "port"},
+	BackendTypePostgres: {"url"},
+}
+
+// BackendTypes returns the supported backend types in name order.
+func BackendTypes() []string {
+	types := make([]string, 0, len(backendRequired))
+	for t := range backendRequired {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// fieldEnums restricts string fields of a config struct to known values.
+var fieldEnums = map[reflect.Type]map[string][]string{
+	reflect.TypeFor[BackendConfig]():      {"type": BackendTypes()},
+	reflect.TypeFor[PrometheusConfig]():   {"type": {"prometheus", "victoriametrics"}},
+	reflect.TypeFor[BaselineConfig]():     {"mode": {BaselineModeStdDev, BaselineModeLastWeek}},
+	reflect.TypeFor[AlertConfig]():        {"generator": {AlertGeneratorLLM, AlertGeneratorTemplate, AlertGeneratorLLMWithFallback}},
+	reflect.TypeFor[LLMConfig]():          {"response_format": {LLMResponseFormatJSONSchema, LLMResponseFormatJSONObject, LLMResponseFormatText}},
+	reflect.TypeFor[InfographicsConfig](): {"renderer": {InfographicsRendererAuto, InfographicsRendererNative, InfographicsRendererGraphviz}},
+}
+
+// configSchema describes Config by its mapstructure tags with the rules that
+// types alone can't express: enums, required fields and per-type backend fields.
+func configSchema() *Schema {
+	root := schemaFor(reflect.TypeFor[Config]())
+	root.SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+	root.Title = "pingr config"
+
+	backend := root.Properties["backends"].AdditionalProperties.(*Schema)
+
+	// Шаблоны и defaults раскрываются при загрузке и описаны только для редактора
+	root.Properties[includeKey] = &Schema{
+		Description: "Files, globs or directories merged into this config, relative to it.",
+		Type:        schemaType{schemaString, schemaArray},
+		Items:       &Schema{Type: schemaType{schemaString}},
+	}
+	defaults := partialBackend(backend)
+	defaults.Description = "Fields applied to every backend before its templates and own fields."
+	root.Properties[defaultsKey] = defaults
+	template := partialBackend(backend)
+	template.Properties[extendsKey] = extendsSchema()
+	root.Properties[templatesKey] = &Schema{
+		Description:          "Named partial backends that backends and other templates can extend.",
+		Type:                 schemaType{schemaObject},
+		AdditionalProperties: template,
+	}
+	backend.Properties[extendsKey] = extendsSchema()
+
+	backend.Required = []string{"type"}
+	for _, t := range BackendTypes() {
+		backend.AllOf = append(backend.AllOf, &Schema{
+			If: &Schema{
+				Properties: map[string]*Schema{"type": {Const: t}},
+				Required:   []string{"type"},
+			},
+			Then: &Schema{Required: backendRequired[t]},
+		})
+	}
+
+	// Блок prometheus может отсутствовать, а у именованного источника без url нет смысла
+	datasource := root.Properties["datasources"].AdditionalProperties.(*Schema)
+	datasource.Required = []string{"url"}
+
+	return root
+}
+
+// JSONSchema returns the config schema as an indented JSON document.
+func JSONSchema() ([]byte, error) {
+	data, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// partialBackend copies the backend schema without required fields.
+func partialBackend(backend *Schema) *Schema {
+	partial := *backend
+	partial.Properties = make(map[string]*Schema, len(backend.Properties))
+	for name, prop := range backend.Properties {
+		partial.Properties[name] = prop
+	}
+	return &partial
+}
+
+func extendsSchema() *Schema {
+	return &Schema{
+		Description: "Templates applied in order before the own fields.",
+		Type:        schemaType{schemaString, schemaArray},
+		Items:       &Schema{Type: schemaType{schemaString}},
+	}
+}
+
+func schemaFor(t reflect.Type) *Schema {
+	if t == reflect.TypeFor[time.Duration]() {
+		return &Schema{Type: schemaType{schemaString, schemaInteger}, Pattern: durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return &Schema{Type: schemaType{schemaString}}
+	case reflect.Bool:
+		return &Schema{Type: schemaType{schemaBoolean}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: schemaType{schemaInteger}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: schemaType{schemaNumber}}
+	case reflect.Slice:
+		return &Schema{Type: schemaType{schemaArray}, Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: schemaType{schemaObject}, AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &Schema{
+			Type:                 schemaType{schemaObject},
+			Properties:           make(map[string]*Schema, t.NumField()),
+			AdditionalProperties: false,
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("mapstructure")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			prop := schemaFor(field.Type)
+			prop.Enum = fieldEnums[t][name]
+			s.Properties[name] = prop
+		}
+		return s
+	default:
+		return &Schema{}
+	}
+}
@@ -2,43 +2,454 @@ package config
 
 import (
 	"fmt"
-	"gopkg.in/go-playground/validator.v9"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Problem is a single validation error at a YAML path, e.g. backends.api.url.
+// File is set when the value comes from an included file.
+type Problem struct {
+	File    string
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	var parts []string
+	if p.File != "" {
+		parts = append(parts, p.File)
+	}
+	if p.Path != "" {
+		parts = append(parts, p.Path)
+	}
+	return strings.Join(append(parts, p.Message), ": ")
+}
+
+// ValidationError lists every problem found in a config, sorted by file and path.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].String()
+	}
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("%d config problems:", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateConfig checks a config built in code against the same rules Load
+// applies to the YAML file.
 func ValidateConfig(config *Config) error {
-	validate := validator.New()
-	validate.RegisterStructValidation(func(sl validator.StructLevel) {
-		cfg := sl.Current().Interface().(BackendConfig)
-		switch cfg.Type {
-		case "http":
-			if cfg.URL == "" {
-				sl.ReportError(cfg.URL, "URL", "url", "required_for_http", "")
+	v := &validator{}
+	doc := toDocument(reflect.ValueOf(config).Elem())
+	if m, ok := doc.(map[string]any); ok {
+		normalizeDocument(m)
+	}
+	v.checkSchema(configSchema(), doc, "")
+	v.checkReferences(config)
+	return v.err()
+}
+
+// removedKeys explain how to migrate keys that older configs may still have.
+var removedKeys = map[string]string{
+	"llm.api_key_env":  "use api_key: ${VAR} instead",
+	"llm.api_key_file": "use api_key: ${file:/path} instead",
+}
+
+type validator struct {
+	problems []Problem
+	warnings []Problem
+	// origins maps paths of values merged from included files to the file
+	origins map[string]string
+
+	allowUnresolved bool
+	// unresolved holds paths of values left unset because of a missing
+	// variable or file; they are not reported as missing again
+	unresolved map[string]bool
+}
+
+func (v *validator) report(path string, format string, args ...any) {
+	v.reportIn(v.fileOf(path), path, format, args...)
+}
+
+func (v *validator) reportIn(file string, path string, format string, args ...any) {
+	v.problems = append(v.problems, Problem{File: file, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warn(path string, format string, args ...any) {
+	v.warnings = append(v.warnings, Problem{File: v.fileOf(path), Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// fileOf returns the included file the value at path comes from, or an empty
+// string for the main file.
+func (v *validator) fileOf(path string) string {
+	for path != "" {
+		if file, ok := v.origins[path]; ok {
+			return file
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return ""
+}
+
+func (v *validator) err() error {
+	sortProblems(v.warnings)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	sortProblems(v.problems)
+	return &ValidationError{Problems: v.problems}
+}
+
+func sortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Path < problems[j].Path
+	})
+}
+
+// checkSchema validates a decoded YAML document. Scalars are accepted in the
+// forms the config decoder converts, e.g. a port given as the string "8080".
+func (v *validator) checkSchema(s *Schema, value any, path string) {
+	if value == nil {
+		return
+	}
+
+	if len(s.Type) > 0 && !matchesType(s, value) {
+		v.report(path, "expected %s, got %s", strings.Join(s.Type, " or "), describe(value))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		str := fmt.Sprint(value)
+		if !contains(s.Enum, str) {
+			v.report(path, "unknown value '%s', expected one of: %s", str, strings.Join(s.Enum, ", "))
+		}
+	}
+
+	if s.Pattern != "" {
+		if str, ok := value.(string); ok && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			v.report(path, "invalid value '%s'", str)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.checkObject(s, value, path)
+	case []any:
+		if s.Items != nil {
+			for i, item := range value {
+				v.checkSchema(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func (v *validator) checkObject(s *Schema, obj map[string]any, path string) {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := joinPath(path, key)
+		if prop, ok := s.Properties[key]; ok {
+			v.checkSchema(prop, obj[key], child)
+			continue
+		}
+		switch extra := s.AdditionalProperties.(type) {
+		case *Schema:
+			v.checkSchema(extra, obj[key], child)
+		case bool:
+			if extra {
+				break
+			}
+			if hint, ok := removedKeys[child]; ok {
+				v.report(child, "no longer supported, %s", hint)
+			} else {
+				v.report(child, "unknown key")
+			}
+		}
+	}
+
+	for _, key := range s.Required {
+		if !present(obj, key) && !v.unresolved[joinPath(path, key)] {
+			v.report(joinPath(path, key), "required")
+		}
+	}
+
+	for _, rule := range s.AllOf {
+		if rule.If != nil && !holds(rule.If, obj) {
+			continue
+		}
+		if rule.Then == nil {
+			continue
+		}
+		for _, key := range rule.Then.Required {
+			if present(obj, key) || v.unresolved[joinPath(path, key)] {
+				continue
+			}
+			if t, ok := obj["type"]; ok {
+				v.report(joinPath(path, key), "required for type %v", t)
+			} else {
+				v.report(joinPath(path, key), "required")
 			}
-		case "grpc":
-			if cfg.Host == "" || cfg.Port == 0 {
-				sl.ReportError(cfg.Host, "Host", "host", "required_for_grpc", "")
-				sl.ReportError(cfg.Port, "Port", "port", "required_for_grpc", "")
+		}
+	}
+}
+
+// holds evaluates an if-condition of const properties and required keys.
+func holds(cond *Schema, obj map[string]any) bool {
+	for _, key := range cond.Required {
+		if !present(obj, key) {
+			return false
+		}
+	}
+	for key, prop := range cond.Properties {
+		if value, ok := obj[key]; ok && fmt.Sprint(value) != prop.Const {
+			return false
+		}
+	}
+	return true
+}
+
+// checkReferences reports what the schema can't express: names that refer to
+// other parts of the config and dependency cycles.
+func (v *validator) checkReferences(config *Config) {
+	datasources := config.AllDatasources()
+
+	for _, name := range sortedKeys(config.Backends) {
+		backend := config.Backends[name]
+		path := joinPath("backends", name)
+		for i, set := range backend.QuerySets {
+			if _, ok := config.QuerySets[set]; !ok {
+				v.report(fmt.Sprintf("%s.query_sets[%d]", path, i), "unknown query set '%s'", set)
+			}
+		}
+		if backend.Datasource != "" {
+			if _, ok := datasources[backend.Datasource]; !ok {
+				v.report(path+".datasource", "unknown datasource '%s'", backend.Datasource)
+			}
+		}
+		for i, dep := range backend.Deps {
+			if _, ok := config.Backends[dep]; !ok {
+				v.report(fmt.Sprintf("%s.deps[%d]", path, i), "unknown dependency '%s'", dep)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(config.QuerySets) {
+		set := config.QuerySets[name]
+		if set.Datasource == "" {
+			continue
+		}
+		if _, ok := datasources[set.Datasource]; !ok {
+			v.report(joinPath("query_sets", name)+".datasource", "unknown datasource '%s'", set.Datasource)
+		}
+	}
+
+	if cycle := findDependencyCycle(config.Backends, sortedKeys(config.Backends)); cycle != nil {
+		v.report(joinPath("backends", cycle[0])+".deps", "dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+}
+
+// normalizeDocument lowercases backend types, which the checker matches
+// case-insensitively, so that type: HTTP passes the schema too.
+func normalizeDocument(doc map[string]any) {
+	backends, _ := doc["backends"].(map[string]any)
+	for _, raw := range backends {
+		backend, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		if t, ok := backend["type"].(string); ok {
+			backend["type"] = strings.ToLower(t)
+		}
+	}
+}
+
+// toDocument turns a config struct into the document form Load validates.
+// Zero values are left out, as if the key were not in the file.
+func toDocument(value reflect.Value) any {
+	if value.Type() == reflect.TypeFor[time.Duration]() {
+		if value.Int() == 0 {
+			return nil
+		}
+		return time.Duration(value.Int()).String()
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+		// Явно заданный ноль по указателю значим, например max_retries: 0
+		if elem := toDocument(value.Elem()); elem != nil {
+			return elem
+		}
+		return value.Elem().Interface()
+	case reflect.Struct:
+		doc := make(map[string]any)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := field.Tag.Get("mapstructure")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			if v := toDocument(value.Field(i)); v != nil {
+				doc[name] = v
+			}
+		}
+		if len(doc) == 0 {
+			return nil
+		}
+		return doc
+	case reflect.Map:
+		if value.Len() == 0 {
+			return nil
+		}
+		doc := make(map[string]any, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			// Пустая запись карты всё равно существует, поэтому остаётся пустым объектом
+			v := toDocument(iter.Value())
+			if v == nil && iter.Value().Kind() == reflect.Struct {
+				v = map[string]any{}
+			}
+			doc[iter.Key().String()] = v
+		}
+		return doc
+	case reflect.Slice:
+		if value.Len() == 0 {
+			return nil
+		}
+		items := make([]any, value.Len())
+		for i := range items {
+			items[i] = toDocument(value.Index(i))
+		}
+		return items
+	default:
+		if value.IsZero() {
+			return nil
+		}
+		return value.Interface()
+	}
+}
+
+func matchesType(s *Schema, value any) bool {
+	for _, t := range s.Type {
+		switch t {
+		case schemaObject:
+			if _, ok := value.(map[string]any); ok {
+				return true
+			}
+		case schemaArray:
+			if _, ok := value.([]any); ok {
+				return true
 			}
-		case "icmp":
-			if cfg.Host == "" {
-				sl.ReportError(cfg.Host, "Host", "host", "required_for_icmp", "")
+		case schemaString:
+			if _, ok := value.(string); ok {
+				return true
 			}
-		case "tcp", "redis":
-			if cfg.Host == "" || cfg.Port == 0 {
-				sl.ReportError(cfg.Host, "Host", "host", "required_for_"+cfg.Type, "")
-				sl.ReportError(cfg.Port, "Port", "port", "required_for_"+cfg.Type, "")
+		case schemaBoolean:
+			switch value := value.(type) {
+			case bool:
+				return true
+			case string:
+				if _, err := strconv.ParseBool(value); err == nil {
+					return true
+				}
 			}
-		case "postgres":
-			if cfg.URL == "" {
-				sl.ReportError(cfg.URL, "URL", "url", "required_for_postgres", "")
+		case schemaInteger:
+			switch value := value.(type) {
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+				return true
+			case float64:
+				if value == float64(int64(value)) {
+					return true
+				}
+			case string:
+				if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+					return true
+				}
+			}
+		case schemaNumber:
+			switch value := value.(type) {
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+				return true
+			case string:
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					return true
+				}
 			}
 		}
-	}, BackendConfig{})
+	}
+	return false
+}
+
+func describe(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "list"
+	case string:
+		return fmt.Sprintf("string '%v'", value)
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T %v", value, value)
+	}
+}
+
+func present(obj map[string]any, key string) bool {
+	value, ok := obj[key]
+	if !ok || value == nil {
+		return false
+	}
+	if str, ok := value.(string); ok {
+		return str != ""
+	}
+	return true
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
 
-	for name, backend := range config.Backends {
-		if err := validate.Struct(backend); err != nil {
-			return fmt.Errorf("invalid config in '%s': %w", name, err)
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
 	}
-	return nil
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
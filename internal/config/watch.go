@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher reports changes of config files, including atomic replacements by
+// editors and Kubernetes ConfigMap updates. The file is not parsed here: the
+// caller reloads it with Load.
+type Watcher struct {
+	fs *fsnotify.Watcher
+
+	mu sync.Mutex
+	// files maps the watched files to the path their symlinks resolve to
+	files map[string]string
+	dirs  map[string]bool
+}
+
+func NewWatcher() (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watch config files: %w", err)
+	}
+	return &Watcher{
+		fs:    fs,
+		files: make(map[string]string),
+		dirs:  make(map[string]bool),
+	}, nil
+}
+
+// Add starts watching file. The directory is watched rather than the file,
+// so a file that is replaced or removed and created again is still followed.
+func (w *Watcher) Add(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", file, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.files[abs]; ok {
+		return nil
+	}
+	dir := filepath.Dir(abs)
+	if !w.dirs[dir] {
+		if err := w.fs.Add(dir); err != nil {
+			return fmt.Errorf("watch %s: %w", file, err)
+		}
+		w.dirs[dir] = true
+	}
+	w.files[abs] = realPath(abs)
+	return nil
+}
+
+// Run calls onChange for every change of a watched file until ctx is done.
+func (w *Watcher) Run(ctx context.Context, onChange func()) {
+	defer w.fs.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if w.changed(event) {
+				onChange()
+			}
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("watch config files", "error", err)
+		}
+	}
+}
+
+func (w *Watcher) changed(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	dir := filepath.Dir(name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	changed := false
+	for file, real := range w.files {
+		if filepath.Dir(file) != dir {
+			continue
+		}
+		if file == name {
+			changed = true
+		}
+		// ConfigMap подменяет симлинк ..data, сам файл событий не получает
+		if now := realPath(file); now != real {
+			w.files[file] = now
+			changed = true
+		}
+	}
+	return changed
+}
+
+func realPath(file string) string {
+	real, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return ""
+	}
+	return real
+}
@@ -0,0 +1,122 @@
+package incident
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+// Error categories of failed checks. Details carry addresses, ports and
+// timings that change between cycles, so the fingerprint uses only the category.
+const (
+	ErrorTimeout           = "timeout"
+	ErrorConnectionRefused = "connection_refused"
+	ErrorDNS               = "dns"
+	ErrorTLS               = "tls"
+	ErrorAuth              = "auth"
+	ErrorHTTP4xx           = "http_4xx"
+	ErrorHTTP5xx           = "http_5xx"
+	ErrorOther             = "other"
+)
+
+var errorCategories = []struct {
+	category string
+	markers  []string
+}{
+	{ErrorTimeout, []string{"timeout", "deadline exceeded", "timed out"}},
+	{ErrorConnectionRefused, []string{"connection refused", "connection reset", "unavailable"}},
+	{ErrorDNS, []string{"no such host", "server misbehaving"}},
+	{ErrorTLS, []string{"tls", "x509", "certificate"}},
+	{ErrorAuth, []string{"authentication", "unauthorized", "permission denied", "http status code: 401", "http status code: 403"}},
+	{ErrorHTTP5xx, []string{"http status code: 5"}},
+	{ErrorHTTP4xx, []string{"http status code: 4"}},
+}
+
+// ErrorCategory classifies the details of a failed check.
+func ErrorCategory(details string) string {
+	lower := strings.ToLower(details)
+	for _, c := range errorCategories {
+		for _, marker := range c.markers {
+			if strings.Contains(lower, marker) {
+				return c.category
+			}
+		}
+	}
+	return ErrorOther
+}
+
+// Fingerprint identifies an incident by failing backends, their error
+// categories and coarse metric buckets. It stays the same while the picture
+// doesn't change materially, e.g. a latency anomaly growing from 4σ to 5σ.
+func Fingerprint(infos map[string]model.SubsystemInfo) string {
+	names := make([]string, 0, len(infos))
+	for name := range infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		info := infos[name]
+		failing := info.Check.Status == model.PingStatusNotOk
+		if failing {
+			parts = append(parts, fmt.Sprintf("%s!%s", name, ErrorCategory(info.Check.Details)))
+		}
+		for _, metric := range info.Metric.Metrics {
+			if bucket, ok := metricBucket(metric, failing); ok {
+				parts = append(parts, fmt.Sprintf("%s/%s~%s", name, metricKey(metric), bucket))
+			}
+		}
+	}
+	sort.Strings(parts)
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// metricBucket puts anomalous metrics into power-of-two buckets of their
+// deviation, and plain metrics of failing backends into orders of magnitude.
+func metricBucket(metric model.Metric, failing bool) (string, bool) {
+	if metric.Baseline != nil {
+		if !metric.Baseline.Anomalous {
+			return "", false
+		}
+		direction := "+"
+		if metric.Baseline.Delta < 0 {
+			direction = "-"
+		}
+		return fmt.Sprintf("%s%d", direction, logBucket(metric.Baseline.Score, 2)), true
+	}
+	if !failing {
+		return "", false
+	}
+	return fmt.Sprintf("%d", logBucket(metric.Value, 10)), true
+}
+
+func logBucket(v float64, base float64) int {
+	v = math.Abs(v)
+	if v < 1 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return int(math.Floor(math.Log(v)/math.Log(base))) + 1
+}
+
+func metricKey(metric model.Metric) string {
+	keys := make([]string, 0, len(metric.Labels))
+	for k := range metric.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(metric.Name)
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf(",%s=%s", k, metric.Labels[k]))
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package incident
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+func TestErrorCategory(t *testing.T) {
+	for details, category := range map[string]string{
+		"dial tcp 10.0.0.1:5432: i/o timeout":                 ErrorTimeout,
+		"Get \"http://api:8080\": context deadline exceeded":  ErrorTimeout,
+		"dial tcp 10.0.0.1:6379: connect: connection refused": ErrorConnectionRefused,
+		"dial tcp: lookup db on 127.0.0.11:53: no such host":  ErrorDNS,
+		"x509: certificate signed by unknown authority":       ErrorTLS,
+		"pq: password authentication failed for user \"app\"": ErrorAuth,
+		"http status code: 502":                               ErrorHTTP5xx,
+		"http status code: 404":                               ErrorHTTP4xx,
+		"http status code: 403":                               ErrorAuth,
+		"something unexpected":                                ErrorOther,
+	} {
+		assert.Equal(t, category, ErrorCategory(details), details)
+	}
+}
+
+func fingerprintInfos(port string, latency, score float64) map[string]model.SubsystemInfo {
+	return map[string]model.SubsystemInfo{
+		"api": {
+			Check: model.CheckResult{Status: model.PingStatusNotOk, Details: "dial tcp 10.0.0.1:" + port + ": connect: connection refused"},
+			Metric: model.MetricsExtractorResult{Metrics: []model.Metric{
+				{Name: "latency", Value: latency, Labels: map[string]string{"job": "api"}},
+			}},
+		},
+		"db": {
+			Check: model.CheckResult{Status: model.PingStatusOk},
+			Metric: model.MetricsExtractorResult{Metrics: []model.Metric{
+				{Name: "connections", Value: 100, Baseline: &model.MetricBaseline{Delta: 50, Score: score, Anomalous: score >= 3}},
+				// Метрики здоровых бэкендов без baseline не влияют на отпечаток
+				{Name: "rps", Value: latency},
+			}},
+		},
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint(fingerprintInfos("8080", 120, 4))
+	assert.Len(t, base, 16)
+
+	// Порты в деталях и небольшие изменения значений не меняют отпечаток
+	assert.Equal(t, base, Fingerprint(fingerprintInfos("8081", 150, 5)))
+
+	// Метрика ушла в другой порядок величины
+	assert.NotEqual(t, base, Fingerprint(fingerprintInfos("8080", 1200, 4)))
+	// Аномалия стала вдвое сильнее
+	assert.NotEqual(t, base, Fingerprint(fingerprintInfos("8080", 120, 9)))
+	// Аномалия пропала
+	assert.NotEqual(t, base, Fingerprint(fingerprintInfos("8080", 120, 1)))
+
+	// Упал ещё один бэкенд
+	infos := fingerprintInfos("8080", 120, 4)
+	db := infos["db"]
+	db.Check = model.CheckResult{Status: model.PingStatusNotOk, Details: "i/o timeout"}
+	infos["db"] = db
+	assert.NotEqual(t, base, Fingerprint(infos))
+}
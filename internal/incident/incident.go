@@ -0,0 +1,41 @@
+// Package incident derives facts about the current incident from check results
+// and the dependency graph.
+package incident
+
+import (
+	"sort"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+// Failing returns the names of unhealthy backends in name order.
+func Failing(infos map[string]model.SubsystemInfo) []string {
+	var failing []string
+	for name, info := range infos {
+		if info.Check.Status == model.PingStatusNotOk {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// Roots returns failing backends none of whose direct dependencies fail,
+// i.e. the most likely origins of the failure, in name order.
+func Roots(cfg config.Config, infos map[string]model.SubsystemInfo) []string {
+	var roots []string
+	for _, name := range Failing(infos) {
+		root := true
+		for _, dep := range cfg.Backends[name].Deps {
+			if dep != name && infos[dep].Check.Status == model.PingStatusNotOk {
+				root = false
+				break
+			}
+		}
+		if root {
+			roots = append(roots, name)
+		}
+	}
+	return roots
+}
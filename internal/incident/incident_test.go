@@ -0,0 +1,31 @@
+package incident
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+func TestRoots(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"front": {Deps: []string{"api"}},
+			"api":   {Deps: []string{"db", "cache"}},
+			"db":    {},
+			"cache": {},
+			"batch": {Deps: []string{"batch"}},
+		},
+	}
+	infos := map[string]model.SubsystemInfo{
+		"front": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"api":   {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"db":    {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"cache": {Check: model.CheckResult{Status: model.PingStatusOk}},
+		"batch": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+	}
+
+	assert.Equal(t, []string{"api", "batch", "db", "front"}, Failing(infos))
+	assert.Equal(t, []string{"batch", "db"}, Roots(cfg, infos))
+}
@@ -0,0 +1,216 @@
+package infographics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/incident"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+const (
+	impactedColor    = "#ffb3a7"
+	propagationColor = "#d62728"
+	dimmedEdgeColor  = "#cccccc"
+	dimmedTextColor  = "#999999"
+	// Насколько бледнее рисуются узлы вне радиуса поражения
+	dimAmount = 0.7
+)
+
+// blastRadius splits the graph by how the failure spreads along the
+// dependencies: roots fail on their own, impacted nodes fail together with a
+// dependency, affected nodes are the failing ones and everything that depends
+// on them. The rest of the graph is drawn dimmed.
+type blastRadius struct {
+	failing  map[string]bool
+	roots    map[string]bool
+	affected map[string]bool
+}
+
+func computeBlastRadius(cfg config.Config, infos map[string]model.SubsystemInfo) blastRadius {
+	b := blastRadius{
+		failing:  make(map[string]bool),
+		roots:    make(map[string]bool),
+		affected: make(map[string]bool),
+	}
+	for name := range cfg.Backends {
+		b.failing[name] = infos[name].Check.Status == model.PingStatusNotOk
+	}
+
+	for _, name := range incident.Roots(cfg, infos) {
+		b.roots[name] = true
+	}
+	// Первопричина из RCA остаётся корнем, даже если у неё есть упавшие зависимости
+	for name := range cfg.Backends {
+		if b.failing[name] && infos[name].SuspectedRootCause {
+			b.roots[name] = true
+		}
+	}
+
+	// Затронуты упавшие бэкенды и все, кто от них зависит. Для циклов
+	// повторный вход в узел считается незатронутым.
+	visiting := make(map[string]bool)
+	done := make(map[string]bool)
+	var visit func(string) bool
+	visit = func(name string) bool {
+		if done[name] {
+			return b.affected[name]
+		}
+		if visiting[name] {
+			return false
+		}
+		visiting[name] = true
+		affected := b.failing[name]
+		for _, dep := range cfg.Backends[name].Deps {
+			if _, ok := cfg.Backends[dep]; ok && dep != name && visit(dep) {
+				affected = true
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		b.affected[name] = affected
+		return affected
+	}
+	for name := range cfg.Backends {
+		visit(name)
+	}
+	return b
+}
+
+func (b blastRadius) hasFailures() bool {
+	for _, failing := range b.failing {
+		if failing {
+			return true
+		}
+	}
+	return false
+}
+
+func (b blastRadius) root(name string) bool {
+	return b.roots[name]
+}
+
+func (b blastRadius) impacted(name string) bool {
+	return b.failing[name] && !b.roots[name]
+}
+
+// dimmed reports whether the node is outside the blast radius. Without
+// failures nothing is dimmed.
+func (b blastRadius) dimmed(name string) bool {
+	return b.hasFailures() && !b.affected[name]
+}
+
+// propagation reports whether the failure spread along the edge from the
+// dependency to its failing dependent.
+func (b blastRadius) propagation(from, to string) bool {
+	return b.failing[from] && b.failing[to]
+}
+
+func (b blastRadius) dimmedEdge(from, to string) bool {
+	return b.dimmed(from) || b.dimmed(to)
+}
+
+// fillColor picks the node color: roots and impacted dependents differ, the
+// rest of the picture fades out of the blast radius.
+func (b blastRadius) fillColor(name string, status model.PingStatus) string {
+	switch {
+	case b.impacted(name):
+		return impactedColor
+	case b.dimmed(name):
+		return dimColor(statusToColor(status))
+	default:
+		return statusToColor(status)
+	}
+}
+
+func (b blastRadius) edgeStyle(from, to string) (string, float64) {
+	switch {
+	case b.propagation(from, to):
+		return propagationColor, 3
+	case b.dimmedEdge(from, to):
+		return dimmedEdgeColor, edgeWidth
+	default:
+		return edgeColor, edgeWidth
+	}
+}
+
+// dimColor mixes the color with white.
+func dimColor(hex string) string {
+	c := parseHexColor(hex)
+	mix := func(v uint8) uint8 {
+		return uint8(float64(v) + (255-float64(v))*dimAmount)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", mix(c.R), mix(c.G), mix(c.B))
+}
+
+// Вид элемента легенды
+type legendKind int
+
+const (
+	legendNode legendKind = iota
+	legendRing
+	legendEdge
+)
+
+type legendEntry struct {
+	kind  legendKind
+	color string
+	// Заливка под кольцом для legendRing
+	fill string
+	text string
+}
+
+// legend lists only what is present on the picture.
+func (b blastRadius) legend(cfg config.Config, infos map[string]model.SubsystemInfo) []legendEntry {
+	var roots, impacted, healthy, unknown, anomalies, propagation, dimmed bool
+	for name, backend := range cfg.Backends {
+		switch {
+		case b.root(name):
+			roots = true
+		case b.impacted(name):
+			impacted = true
+		case infos[name].Check.Status == model.PingStatusOk:
+			healthy = true
+		default:
+			unknown = true
+		}
+		anomalies = anomalies || hasAnomaly(infos[name])
+		dimmed = dimmed || b.dimmed(name)
+		for _, dep := range backend.Deps {
+			if _, ok := cfg.Backends[dep]; ok && dep != name && b.propagation(name, dep) {
+				propagation = true
+			}
+		}
+	}
+
+	var entries []legendEntry
+	add := func(present bool, entry legendEntry) {
+		if present {
+			entries = append(entries, entry)
+		}
+	}
+	add(roots, legendEntry{kind: legendRing, color: rootCauseColor, fill: statusToColor(model.PingStatusNotOk), text: "root cause: failing, dependencies are fine"})
+	add(impacted, legendEntry{kind: legendNode, color: impactedColor, text: "impacted: failing with a dependency"})
+	add(healthy, legendEntry{kind: legendNode, color: statusToColor(model.PingStatusOk), text: "healthy"})
+	add(unknown, legendEntry{kind: legendNode, color: statusToColor(""), text: "no data"})
+	add(anomalies, legendEntry{kind: legendRing, color: anomalyColor, fill: "#ffffff", text: "metric anomaly"})
+	add(propagation, legendEntry{kind: legendEdge, color: propagationColor, text: "failure propagation"})
+	add(dimmed, legendEntry{kind: legendNode, color: dimColor(statusToColor(model.PingStatusOk)), text: "outside the blast radius"})
+	return entries
+}
+
+// header names the incident and the moment of the snapshot.
+func header(cfg config.Config, infos map[string]model.SubsystemInfo, now time.Time) []string {
+	failing := 0
+	for name := range cfg.Backends {
+		if infos[name].Check.Status == model.PingStatusNotOk {
+			failing++
+		}
+	}
+
+	return []string{
+		"Incident " + incident.Fingerprint(infos),
+		fmt.Sprintf("%s, %d of %d backends failing", now.UTC().Format("2006-01-02 15:04:05 MST"), failing, len(cfg.Backends)),
+	}
+}
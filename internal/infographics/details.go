@@ -0,0 +1,173 @@
+package infographics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/redact"
+)
+
+const (
+	// Сколько метрик показывать под узлом, остальные сворачиваются в "+N more"
+	maxKeyMetrics = 3
+	// Длиннее подписи обрезаются, чтобы узлы не разъезжались
+	maxDetailChars = 48
+	// Ряд метрики ужимается до стольких точек
+	sparklinePoints = 20
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// detailLine is a caption line under a node, optionally with a sparkline.
+type detailLine struct {
+	text   string
+	series []float64
+}
+
+// nodeDetails describes what broke and how badly: the RCA mark, the failing
+// check details and the key metrics with their recent history. The text ends
+// up in a picture sent to the chat, so it is redacted like any alert text.
+func nodeDetails(info model.SubsystemInfo, redactor *redact.Redactor) []detailLine {
+	var lines []detailLine
+	if info.SuspectedRootCause {
+		lines = append(lines, detailLine{text: "suspected root cause"})
+	}
+
+	if info.Check.Status == model.PingStatusNotOk {
+		if details := strings.Join(strings.Fields(redactor.Redact(info.Check.Details)), " "); details != "" {
+			lines = append(lines, detailLine{text: truncate(details, maxDetailChars)})
+		}
+	}
+
+	metrics, hidden := keyMetrics(info)
+	for _, metric := range metrics {
+		lines = append(lines, detailLine{text: truncate(redactor.Redact(metricText(metric)), maxDetailChars), series: metric.Series})
+	}
+	if hidden > 0 {
+		lines = append(lines, detailLine{text: fmt.Sprintf("+%d more metrics", hidden)})
+	}
+	return lines
+}
+
+// keyMetrics picks anomalous metrics, strongest first, and for a failing
+// backend fills the rest with other metrics in query order. Healthy backends
+// without anomalies show nothing to keep the picture readable.
+func keyMetrics(info model.SubsystemInfo) ([]model.Metric, int) {
+	var anomalous, rest []model.Metric
+	for _, metric := range info.Metric.Metrics {
+		if metric.Baseline != nil && metric.Baseline.Anomalous {
+			anomalous = append(anomalous, metric)
+		} else {
+			rest = append(rest, metric)
+		}
+	}
+	sort.SliceStable(anomalous, func(i, j int) bool {
+		return math.Abs(anomalous[i].Baseline.Score) > math.Abs(anomalous[j].Baseline.Score)
+	})
+
+	candidates := anomalous
+	if info.Check.Status == model.PingStatusNotOk {
+		candidates = append(candidates, rest...)
+	}
+	if len(candidates) <= maxKeyMetrics {
+		return candidates, 0
+	}
+	return candidates[:maxKeyMetrics], len(candidates) - maxKeyMetrics
+}
+
+func hasAnomaly(info model.SubsystemInfo) bool {
+	for _, metric := range info.Metric.Metrics {
+		if metric.Baseline != nil && metric.Baseline.Anomalous {
+			return true
+		}
+	}
+	return false
+}
+
+func metricText(metric model.Metric) string {
+	text := fmt.Sprintf("%s = %s", metric.Name, formatValue(metric.Value))
+	if metric.Baseline != nil && metric.Baseline.Anomalous {
+		text += fmt.Sprintf(" (%s)", metric.Baseline)
+	}
+	return text
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+func truncate(s string, maxChars int) string {
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:maxChars-2])) + ".."
+}
+
+// resample shrinks values to at most n points by averaging equal buckets.
+// NaN and infinite samples, e.g. a rate over an empty window, are dropped.
+func resample(values []float64, n int) []float64 {
+	values = finite(values)
+	if len(values) <= n {
+		return values
+	}
+	out := make([]float64, n)
+	for i := range out {
+		from, to := i*len(values)/n, (i+1)*len(values)/n
+		var sum float64
+		for _, v := range values[from:to] {
+			sum += v
+		}
+		out[i] = sum / float64(to-from)
+	}
+	return out
+}
+
+func finite(values []float64) []float64 {
+	var out []float64
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// normalize maps values to [0, 1]; a flat series sits in the middle.
+func normalize(values []float64) []float64 {
+	values = finite(values)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	out := make([]float64, len(values))
+	for i, v := range values {
+		if hi == lo {
+			out[i] = 0.5
+		} else {
+			out[i] = (v - lo) / (hi - lo)
+		}
+	}
+	return out
+}
+
+// sparklineText draws a series with unicode blocks for DOT labels.
+func sparklineText(series []float64) string {
+	if len(series) < 2 {
+		return ""
+	}
+	values := normalize(resample(series, sparklinePoints))
+	if len(values) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := int(math.Round(v * float64(len(sparkBlocks)-1)))
+		b.WriteRune(sparkBlocks[max(0, min(i, len(sparkBlocks)-1))])
+	}
+	return b.String()
+}
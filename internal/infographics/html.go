@@ -0,0 +1,147 @@
+package infographics
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/redact"
+)
+
+var htmlSnapshot = template.Must(template.New("snapshot").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>pingr: {{.Failing}} of {{len .Backends}} backends failing</title>
+<style>
+body { font-family: "DejaVu Sans", sans-serif; font-size: 14px; color: #1a1a1a; margin: 24px; }
+table { border-collapse: collapse; margin-top: 16px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+.status { display: inline-block; width: 10px; height: 10px; border-radius: 5px; margin-right: 4px; }
+.spark { font-size: 12px; letter-spacing: -1px; color: #1f5fa8; }
+.root-cause { color: #8b0000; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>pingr: {{.Failing}} of {{len .Backends}} backends failing</h1>
+<p>Snapshot taken at {{.GeneratedAt}}</p>
+{{.Graph}}
+<table>
+<tr><th>Backend</th><th>Status</th><th>Details</th><th>Key metrics</th><th>Links</th></tr>
+{{- range .Backends}}
+<tr id="backend-{{.Name}}">
+<td>{{.Name}}{{if .SuspectedRootCause}} <span class="root-cause">suspected root cause</span>{{end}}</td>
+<td><span class="status" style="background: {{.Color}}"></span>{{.Status}}</td>
+<td>{{.Details}}</td>
+<td>{{range .Metrics}}{{.Text}} <span class="spark">{{.Sparkline}}</span><br>{{end}}</td>
+<td>{{if .RunbookURL}}<a href="{{.RunbookURL}}" target="_blank">runbook</a> {{end}}{{if .DashboardURL}}<a href="{{.DashboardURL}}" target="_blank">dashboard</a>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+type htmlSnapshotData struct {
+	GeneratedAt string
+	Failing     int
+	Graph       template.HTML
+	Backends    []htmlBackend
+}
+
+type htmlBackend struct {
+	Name               string
+	Status             string
+	Color              template.CSS
+	Details            string
+	SuspectedRootCause bool
+	Metrics            []htmlMetric
+	RunbookURL         string
+	DashboardURL       string
+}
+
+type htmlMetric struct {
+	Text      string
+	Sparkline string
+}
+
+// renderHTML builds a self-contained page: the inline SVG graph and a table
+// with the details, key metrics and links of every backend.
+func renderHTML(cfg config.Config, infos map[string]model.SubsystemInfo, svg []byte, now time.Time, redactor *redact.Redactor) ([]byte, error) {
+	// У SVG от graphviz есть XML-пролог и DOCTYPE, внутри HTML они не нужны
+	if i := bytes.Index(svg, []byte("<svg")); i > 0 {
+		svg = svg[i:]
+	}
+
+	data := htmlSnapshotData{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		// SVG рисуется самим рендером, экранировать его не нужно
+		Graph: template.HTML(svg),
+	}
+
+	names := make([]string, 0, len(cfg.Backends))
+	for name := range cfg.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		info := infos[name]
+		backend := cfg.Backends[name]
+		status := info.Check.Status
+		if status == model.PingStatusNotOk {
+			data.Failing++
+		}
+		if status == "" {
+			status = "unknown"
+		}
+
+		row := htmlBackend{
+			Name:               name,
+			Status:             string(status),
+			Color:              template.CSS(statusToColor(info.Check.Status)),
+			Details:            redactor.Redact(info.Check.Details),
+			SuspectedRootCause: info.SuspectedRootCause,
+			RunbookURL:         safeLink(backend.RunbookURL),
+			DashboardURL:       safeLink(backend.DashboardURL),
+		}
+		metrics, hidden := keyMetrics(info)
+		for _, metric := range metrics {
+			row.Metrics = append(row.Metrics, htmlMetric{Text: redactor.Redact(metricText(metric)), Sparkline: sparklineText(metric.Series)})
+		}
+		if hidden > 0 {
+			row.Metrics = append(row.Metrics, htmlMetric{Text: fmt.Sprintf("+%d more metrics", hidden)})
+		}
+		data.Backends = append(data.Backends, row)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlSnapshot.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("execute html template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// nodeLink is where a click on the node leads: the runbook tells what to do,
+// so it wins over the dashboard.
+func nodeLink(backend config.BackendConfig) string {
+	if link := safeLink(backend.RunbookURL); link != "" {
+		return link
+	}
+	return safeLink(backend.DashboardURL)
+}
+
+// safeLink keeps only absolute http(s) URLs, the SVG is written by hand and
+// must not get a javascript: link from the config.
+func safeLink(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return link
+}
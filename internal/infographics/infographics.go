@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -11,34 +12,114 @@ import (
 
 	"github.com/unicoooorn/pingr/internal/config"
 	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/redact"
 	"github.com/unicoooorn/pingr/internal/service"
 )
 
 var _ service.InfographicsRenderer = &ImageRenderer{}
 
+const (
+	contentTypePNG  = "image/png"
+	contentTypeSVG  = "image/svg+xml"
+	contentTypeHTML = "text/html; charset=utf-8"
+)
+
 type ImageRenderer struct {
-	cfg     config.Config
-	timeout time.Duration
+	cfg      config.Config
+	timeout  time.Duration
+	renderer string
+	lookPath func(string) (string, error)
+	now      func() time.Time
+	redactor *redact.Redactor
 }
 
-func NewImageRenderer(cfg config.Config, timeout time.Duration) *ImageRenderer {
+func NewImageRenderer(cfg config.Config, timeout time.Duration) (*ImageRenderer, error) {
 	if timeout == 0 {
 		timeout = 10 * time.Second
 	}
-	return &ImageRenderer{
-		cfg:     cfg,
-		timeout: timeout,
+	renderer := cfg.Infographics.Renderer
+	if renderer == "" {
+		renderer = config.InfographicsRendererAuto
 	}
+	redactor, err := redact.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageRenderer{
+		cfg:      cfg,
+		timeout:  timeout,
+		renderer: renderer,
+		lookPath: exec.LookPath,
+		now:      time.Now,
+		redactor: redactor,
+	}, nil
 }
 
-func (ir *ImageRenderer) Render(ctx context.Context, infos map[string]model.SubsystemInfo) ([]byte, error) {
+// Render draws the graph as PNG, SVG with clickable nodes or a self-contained
+// HTML page with the SVG and a per-backend table.
+func (ir *ImageRenderer) Render(
+	ctx context.Context,
+	infos map[string]model.SubsystemInfo,
+	format model.InfographicFormat,
+) (model.Infographic, error) {
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return model.Infographic{}, err
 	}
 
-	dot := ir.buildDOTFromConfig(infos)
+	switch format {
+	case model.InfographicFormatPNG:
+		data, err := ir.renderPNG(ctx, infos)
+		if err != nil {
+			return model.Infographic{}, err
+		}
+		return model.Infographic{Data: data, ContentType: contentTypePNG}, nil
+	case model.InfographicFormatSVG:
+		data, err := ir.renderSVG(ctx, infos)
+		if err != nil {
+			return model.Infographic{}, err
+		}
+		return model.Infographic{Data: data, ContentType: contentTypeSVG}, nil
+	case model.InfographicFormatHTML:
+		svg, err := ir.renderSVG(ctx, infos)
+		if err != nil {
+			return model.Infographic{}, err
+		}
+		data, err := renderHTML(ir.cfg, infos, svg, ir.now(), ir.redactor)
+		if err != nil {
+			return model.Infographic{}, err
+		}
+		return model.Infographic{Data: data, ContentType: contentTypeHTML}, nil
+	default:
+		return model.Infographic{}, fmt.Errorf("unsupported infographics format %q", format)
+	}
+}
+
+func (ir *ImageRenderer) renderPNG(ctx context.Context, infos map[string]model.SubsystemInfo) ([]byte, error) {
+	if ir.useGraphviz() {
+		return renderDOT(ctx, ir.buildDOTFromConfig(infos), "png", ir.timeout)
+	}
+	return renderNativePNG(ir.layout(infos))
+}
 
-	return renderDOTToPNG(ctx, dot, ir.timeout)
+func (ir *ImageRenderer) renderSVG(ctx context.Context, infos map[string]model.SubsystemInfo) ([]byte, error) {
+	if ir.useGraphviz() {
+		return renderDOT(ctx, ir.buildDOTFromConfig(infos), "svg", ir.timeout)
+	}
+	return renderNativeSVG(ir.layout(infos)), nil
+}
+
+// useGraphviz reports whether to shell out to dot. In auto mode a missing
+// binary is not an error: the native renderer draws the same layout.
+func (ir *ImageRenderer) useGraphviz() bool {
+	switch ir.renderer {
+	case config.InfographicsRendererGraphviz:
+		return true
+	case config.InfographicsRendererNative:
+		return false
+	default:
+		_, err := ir.lookPath("dot")
+		return err == nil
+	}
 }
 
 func htmlEscape(s string) string {
@@ -52,8 +133,8 @@ func htmlLabelFor(labelText string, fontFace string, pointSize int) string {
 	if labelText == "" {
 		labelText = ""
 	}
-	labelText = strings.ReplaceAll(labelText, "\n", "<BR/>")
-	esc := htmlEscape(labelText)
+	// Перевод строки заменяется после экранирования, иначе <BR/> попадёт в текст
+	esc := strings.ReplaceAll(htmlEscape(labelText), "\n", "<BR/>")
 	return fmt.Sprintf("<<TABLE BORDER=\"0\" CELLBORDER=\"0\" CELLSPACING=\"0\"><TR><TD ALIGN=\"center\" VALIGN=\"middle\"><FONT FACE=\"%s\" POINT-SIZE=\"%d\">%s</FONT></TD></TR></TABLE>>",
 		fontFace, pointSize, esc)
 }
@@ -77,6 +158,10 @@ func (ir *ImageRenderer) buildDOTFromConfig(infos map[string]model.SubsystemInfo
 
 	b.WriteString("edge [color=\"#333333\", penwidth=1.0, arrowsize=0.9, arrowhead=normal, headclip=true, tailclip=true];\n")
 
+	blast := computeBlastRadius(ir.cfg, infos)
+	b.WriteString(fmt.Sprintf("labelloc=\"t\"; labeljust=\"l\"; fontname=\"%s\";\n", fontFace))
+	b.WriteString("label=" + dotHeaderLabel(header(ir.cfg, infos, ir.now()), blast.legend(ir.cfg, infos), fontSize) + ";\n")
+
 	ranks := make(map[int][]string)
 	for name := range ir.cfg.Backends {
 		depth := depths[name]
@@ -89,15 +174,44 @@ func (ir *ImageRenderer) buildDOTFromConfig(infos map[string]model.SubsystemInfo
 		labelHTML := htmlLabelFor(labelText, fontFace, fontSize)
 
 		status := model.PingStatus(infos[name].Check.Status)
-		color := statusToColor(status)
+		color := blast.fillColor(name, status)
 
-		b.WriteString(fmt.Sprintf("%s [label=%s, fillcolor=%s];\n",
-			escapeID(name), labelHTML, strconv.Quote(color)))
+		attrs := []string{"label=" + labelHTML, "fillcolor=" + strconv.Quote(color)}
+		switch {
+		case blast.root(name):
+			// Первопричина важнее аномалий, поэтому её рамка перекрывает их цвет
+			attrs = append(attrs, "shape=doublecircle", "color="+strconv.Quote(rootCauseColor), "penwidth=4")
+		case hasAnomaly(infos[name]):
+			attrs = append(attrs, "color="+strconv.Quote(anomalyColor), "penwidth=3")
+		}
+		if blast.dimmed(name) {
+			attrs = append(attrs, "fontcolor="+strconv.Quote(dimmedTextColor))
+		}
+		var xlabel []string
+		for _, line := range nodeDetails(infos[name], ir.redactor) {
+			if spark := sparklineText(line.series); spark != "" {
+				line.text += " " + spark
+			}
+			xlabel = append(xlabel, line.text)
+		}
+		if len(xlabel) > 0 {
+			attrs = append(attrs, "xlabel="+htmlLabelFor(strings.Join(xlabel, "\n"), fontFace, fontSize-2))
+		}
+		// Ссылка работает только в SVG, в PNG graphviz её игнорирует
+		if link := nodeLink(backend); link != "" {
+			attrs = append(attrs, "URL="+strconv.Quote(link), `target="_blank"`, "tooltip="+strconv.Quote(name))
+		}
+		b.WriteString(fmt.Sprintf("%s [%s];\n", escapeID(name), strings.Join(attrs, ", ")))
 
 		for _, dep := range backend.Deps {
 			if dep == "" {
 				continue
 			}
+			if _, ok := ir.cfg.Backends[dep]; ok && dep != name && (blast.propagation(name, dep) || blast.dimmedEdge(name, dep)) {
+				edgeColor, width := blast.edgeStyle(name, dep)
+				b.WriteString(fmt.Sprintf("%s -> %s [color=%q, penwidth=%g];\n", escapeID(name), escapeID(dep), edgeColor, width))
+				continue
+			}
 			b.WriteString(fmt.Sprintf("%s -> %s;\n", escapeID(name), escapeID(dep)))
 		}
 	}
@@ -118,7 +232,34 @@ func (ir *ImageRenderer) buildDOTFromConfig(infos map[string]model.SubsystemInfo
 	return b.String()
 }
 
-func renderDOTToPNG(ctx context.Context, dot string, timeout time.Duration) ([]byte, error) {
+// dotHeaderLabel is the graph label with the incident header and the legend.
+func dotHeaderLabel(header []string, legend []legendEntry, fontSize int) string {
+	var b strings.Builder
+	b.WriteString(`<<TABLE BORDER="0" CELLBORDER="0" CELLSPACING="2">`)
+	for i, line := range header {
+		text := htmlEscape(line)
+		if i == 0 {
+			text = "<B>" + text + "</B>"
+		}
+		b.WriteString(fmt.Sprintf(`<TR><TD COLSPAN="2" ALIGN="LEFT"><FONT POINT-SIZE="%d">%s</FONT></TD></TR>`, fontSize, text))
+	}
+	for _, entry := range legend {
+		var swatch string
+		switch entry.kind {
+		case legendRing:
+			swatch = fmt.Sprintf(`<TD WIDTH="14" HEIGHT="14" BORDER="2" COLOR="%s" BGCOLOR="%s"></TD>`, entry.color, entry.fill)
+		case legendEdge:
+			swatch = fmt.Sprintf(`<TD WIDTH="14"><FONT COLOR="%s"><B>━━</B></FONT></TD>`, entry.color)
+		default:
+			swatch = fmt.Sprintf(`<TD WIDTH="14" HEIGHT="14" BGCOLOR="%s"></TD>`, entry.color)
+		}
+		b.WriteString(fmt.Sprintf(`<TR>%s<TD ALIGN="LEFT"><FONT POINT-SIZE="%d">%s</FONT></TD></TR>`, swatch, fontSize-2, htmlEscape(entry.text)))
+	}
+	b.WriteString(`</TABLE>>`)
+	return b.String()
+}
+
+func renderDOT(ctx context.Context, dot string, format string, timeout time.Duration) ([]byte, error) {
 	ctx2, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -126,7 +267,7 @@ func renderDOTToPNG(ctx context.Context, dot string, timeout time.Duration) ([]b
 		return nil, fmt.Errorf("graphviz not installed: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx2, "dot", "-Tpng")
+	cmd := exec.CommandContext(ctx2, "dot", "-T"+format)
 	cmd.Stdin = strings.NewReader(dot)
 	
 	var stdout, stderr bytes.Buffer
@@ -140,6 +281,11 @@ func renderDOTToPNG(ctx context.Context, dot string, timeout time.Duration) ([]b
 	return stdout.Bytes(), nil
 }
 
+const (
+	anomalyColor   = "#ff9f1c"
+	rootCauseColor = "#8b0000"
+)
+
 func statusToColor(status model.PingStatus) string {
 	switch status {
 	case model.PingStatusOk:
@@ -155,8 +301,12 @@ func escapeID(id string) string {
 	return strconv.Quote(id)
 }
 
+// computeDepths returns the length of the longest dependency chain below every
+// backend. Validation rejects cycles, but the graph is still drawn if one slips
+// through: an edge back into the current path is ignored.
 func computeDepths(cfg config.Config) (map[string]int, int) {
 	depths := make(map[string]int)
+	visiting := make(map[string]bool)
 	var dfs func(string) int
 	dfs = func(name string) int {
 		if d, ok := depths[name]; ok {
@@ -171,9 +321,10 @@ func computeDepths(cfg config.Config) (map[string]int, int) {
 			depths[name] = 0
 			return 0
 		}
-		maxd := 0
+		visiting[name] = true
+		maxd := -1
 		for _, dep := range bc.Deps {
-			if dep == name {
+			if dep == name || visiting[dep] {
 				continue
 			}
 			dd := dfs(dep)
@@ -181,12 +332,20 @@ func computeDepths(cfg config.Config) (map[string]int, int) {
 				maxd = dd
 			}
 		}
+		visiting[name] = false
 		depths[name] = maxd + 1
 		return depths[name]
 	}
 
-	maxDepth := 0
+	// Порядок обхода задаёт, какое ребро цикла будет отброшено, поэтому он фиксирован
+	names := make([]string, 0, len(cfg.Backends))
 	for name := range cfg.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	maxDepth := 0
+	for _, name := range names {
 		d := dfs(name)
 		if d > maxDepth {
 			maxDepth = d
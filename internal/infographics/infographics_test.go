@@ -3,11 +3,18 @@ package infographics
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"os/exec"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/incident"
 	"github.com/unicoooorn/pingr/internal/model"
 )
 
@@ -24,7 +31,7 @@ func TestBuildDOTFromConfig_Basic(t *testing.T) {
 		"B": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
 	}
 
-	ir := NewImageRenderer(cfg, 0)
+	ir := newTestRenderer(t, cfg, 0)
 	dot := ir.buildDOTFromConfig(infos)
 
 	if !strings.Contains(dot, `"A" [label=<<TABLE`) {
@@ -66,13 +73,17 @@ func TestRender_ReturnsPNG(t *testing.T) {
 		"B": {Check: model.CheckResult{Status: model.PingStatusOk}},
 	}
 
-	ir := NewImageRenderer(cfg, 5*time.Second)
+	ir := newTestRenderer(t, cfg, 5*time.Second)
 	ctx := context.Background()
 
-	img, err := ir.Render(ctx, infos)
+	infographic, err := ir.Render(ctx, infos, model.InfographicFormatPNG)
 	if err != nil {
 		t.Fatalf("Render returned error: %v", err)
 	}
+	if infographic.ContentType != "image/png" {
+		t.Fatalf("unexpected content type %q", infographic.ContentType)
+	}
+	img := infographic.Data
 	if len(img) == 0 {
 		t.Fatalf("Render returned empty image")
 	}
@@ -112,6 +123,34 @@ func TestComputeDepths(t *testing.T) {
 	}
 }
 
+func TestComputeDepths_Cycle(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"A": {Deps: []string{"B"}},
+			"B": {Deps: []string{"C"}},
+			"C": {Deps: []string{"A"}},
+			"D": {Deps: []string{"A"}},
+		},
+	}
+
+	// Раньше цикл уводил рекурсию в переполнение стека
+	depths, maxDepth := computeDepths(cfg)
+	if maxDepth != 3 {
+		t.Fatalf("expected maxDepth=3, got %d (%v)", maxDepth, depths)
+	}
+	if !(depths["D"] > depths["A"] && depths["A"] > depths["B"] && depths["B"] > depths["C"]) {
+		t.Fatalf("expected the C -> A edge to be dropped; got %v", depths)
+	}
+
+	infos := map[string]model.SubsystemInfo{
+		"A": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+	}
+	cfg.Infographics.Renderer = config.InfographicsRendererNative
+	if _, err := newTestRenderer(t, cfg, 0).Render(context.Background(), infos, model.InfographicFormatPNG); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+}
+
 func TestStatusToColor(t *testing.T) {
 	tests := []struct {
 		status model.PingStatus
@@ -161,7 +200,7 @@ func TestBuildDOTFromConfig_EmptyDeps(t *testing.T) {
 		"B": {Check: model.CheckResult{Status: model.PingStatusOk}},
 	}
 
-	ir := NewImageRenderer(cfg, 0)
+	ir := newTestRenderer(t, cfg, 0)
 	dot := ir.buildDOTFromConfig(infos)
 
 	if strings.Contains(dot, `"B" -> ""`) {
@@ -193,4 +232,501 @@ func TestHTMLEscape(t *testing.T) {
 			t.Errorf("htmlEscape(%q) = %q, want %q", tt.input, got, tt.want)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestBuildDOTFromConfig_Anomalies(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"A": {},
+		},
+	}
+
+	infos := map[string]model.SubsystemInfo{
+		"A": {
+			Check: model.CheckResult{Status: model.PingStatusOk},
+			Metric: model.MetricsExtractorResult{
+				Metrics: []model.Metric{
+					{
+						Name:  "latency",
+						Value: 16,
+						Baseline: &model.MetricBaseline{
+							Mode: config.BaselineModeStdDev, Mean: 10, StdDev: 1, Delta: 6, Score: 6, Anomalous: true,
+						},
+					},
+					{Name: "rps", Value: 100},
+				},
+			},
+		},
+	}
+
+	ir := newTestRenderer(t, cfg, 0)
+	dot := ir.buildDOTFromConfig(infos)
+
+	if !strings.Contains(dot, "xlabel=") || !strings.Contains(dot, "latency = 16 (6.0σ above baseline)") {
+		t.Fatalf("expected anomaly xlabel for node A; got: %s", dot)
+	}
+	if strings.Contains(dot, "rps") {
+		t.Fatalf("non-anomalous metric of a healthy node must not be drawn; got: %s", dot)
+	}
+}
+
+func TestBuildDOTFromConfig_FailingNodeDetails(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"A": {},
+		},
+	}
+
+	infos := map[string]model.SubsystemInfo{
+		"A": {
+			Check: model.CheckResult{Status: model.PingStatusNotOk, Details: "GET /health: 503 Service Unavailable"},
+			Metric: model.MetricsExtractorResult{
+				Metrics: []model.Metric{
+					{Name: "rps", Value: 100, Series: []float64{100, 100, 100}},
+					{
+						Name:   "errors",
+						Value:  50,
+						Series: []float64{0, 0, 1, 10, 50},
+						Baseline: &model.MetricBaseline{
+							Mode: config.BaselineModeStdDev, Mean: 1, StdDev: 1, Delta: 49, Score: 49, Anomalous: true,
+						},
+					},
+					{Name: "cpu", Value: 0.5},
+					{Name: "memory", Value: 0.7},
+				},
+			},
+		},
+	}
+
+	ir := newTestRenderer(t, cfg, 0)
+	dot := ir.buildDOTFromConfig(infos)
+
+	// Аномальная метрика идёт первой, остальные обрезаются до maxKeyMetrics
+	for _, want := range []string{
+		"GET /health: 503 Service Unavailable<BR/>errors = 50 (49.0σ above baseline) ▁▁▁▂█<BR/>rps = 100 ▅▅▅<BR/>cpu = 0.5<BR/>+1 more metrics",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expected %q in dot; got: %s", want, dot)
+		}
+	}
+}
+
+func TestSparklineText(t *testing.T) {
+	if got := sparklineText([]float64{1}); got != "" {
+		t.Fatalf("single point must not produce a sparkline; got %q", got)
+	}
+	if got := sparklineText([]float64{0, 1, 2, 3, 4, 5, 6, 7}); got != "▁▂▃▄▅▆▇█" {
+		t.Fatalf("unexpected sparkline %q", got)
+	}
+	series := make([]float64, 100)
+	for i := range series {
+		series[i] = float64(i)
+	}
+	if got := []rune(sparklineText(series)); len(got) != sparklinePoints || got[0] != '▁' || got[len(got)-1] != '█' {
+		t.Fatalf("expected %d resampled points; got %q", sparklinePoints, string(got))
+	}
+}
+
+func TestSparklineText_NonFinite(t *testing.T) {
+	// 0/0 в rate даёт NaN, деление на ноль даёт Inf
+	series := []float64{0, math.NaN(), 1, math.Inf(1), 2, math.Inf(-1)}
+	if got := sparklineText(series); got != "▁▅█" {
+		t.Fatalf("non-finite samples must be dropped; got %q", got)
+	}
+	if got := sparklineText([]float64{math.NaN(), math.NaN(), math.Inf(1)}); got != "" {
+		t.Fatalf("series without finite samples must not produce a sparkline; got %q", got)
+	}
+}
+
+func TestRender_NonFiniteSeries(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	db := infos["db"]
+	db.Metric.Metrics = []model.Metric{{Name: "error_ratio", Value: math.NaN(), Series: []float64{math.NaN(), 1, math.Inf(1), 0, math.NaN()}}}
+	infos["db"] = db
+
+	ir := newTestRenderer(t, cfg, 0)
+	if dot := ir.buildDOTFromConfig(infos); !strings.Contains(dot, "error_ratio = NaN") {
+		t.Fatalf("expected NaN metric in dot; got: %s", dot)
+	}
+	for _, format := range []model.InfographicFormat{model.InfographicFormatPNG, model.InfographicFormatSVG, model.InfographicFormatHTML} {
+		if _, err := ir.Render(context.Background(), infos, format); err != nil {
+			t.Fatalf("Render %s returned error: %v", format, err)
+		}
+	}
+}
+
+func TestRender_RedactsDetails(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	cfg.Backends["db"] = config.BackendConfig{Type: "postgres", URL: "postgres://pingr:s3cr3tpass@db:5432/app"}
+	db := infos["db"]
+	db.Check.Details = "pingr:s3cr3tpass@db refused"
+	db.Metric.Metrics = []model.Metric{{Name: "token=abcdef", Value: 1}}
+	infos["db"] = db
+
+	ir := newTestRenderer(t, cfg, 0)
+	outputs := map[string]string{"dot": ir.buildDOTFromConfig(infos)}
+	for _, format := range []model.InfographicFormat{model.InfographicFormatSVG, model.InfographicFormatHTML} {
+		infographic, err := ir.Render(context.Background(), infos, format)
+		if err != nil {
+			t.Fatalf("Render %s returned error: %v", format, err)
+		}
+		outputs[string(format)] = string(infographic.Data)
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, "s3cr3tpass") || strings.Contains(out, "abcdef") {
+			t.Fatalf("%s leaks a secret: %s", name, out)
+		}
+		if !strings.Contains(out, "refused") {
+			t.Fatalf("%s lost the details: %s", name, out)
+		}
+	}
+}
+
+func TestBuildDOTFromConfig_SuspectedRootCause(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"A": {Deps: []string{"B"}},
+			"B": {},
+		},
+	}
+
+	infos := map[string]model.SubsystemInfo{
+		"A": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"B": {Check: model.CheckResult{Status: model.PingStatusNotOk}, SuspectedRootCause: true},
+	}
+
+	ir := newTestRenderer(t, cfg, 0)
+	dot := ir.buildDOTFromConfig(infos)
+
+	if !strings.Contains(dot, `"B" [label=`) || !strings.Contains(dot, "shape=doublecircle") {
+		t.Fatalf("expected root cause node B to be highlighted; got: %s", dot)
+	}
+	if strings.Count(dot, "shape=doublecircle") != 1 || !strings.Contains(dot, "suspected root cause") {
+		t.Fatalf("expected exactly one highlighted node; got: %s", dot)
+	}
+}
+
+func newTestRenderer(t *testing.T, cfg config.Config, timeout time.Duration) *ImageRenderer {
+	t.Helper()
+	ir, err := NewImageRenderer(cfg, timeout)
+	if err != nil {
+		t.Fatalf("NewImageRenderer returned error: %v", err)
+	}
+	return ir
+}
+
+func nativeTestGraph() (config.Config, map[string]model.SubsystemInfo) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"front": {Deps: []string{"api"}, RunbookURL: "javascript:alert(1)"},
+			"api":   {Deps: []string{"db"}, DashboardURL: "https://grafana.example.com/d/api"},
+			"db":    {RunbookURL: "https://wiki.example.com/db", DashboardURL: "https://grafana.example.com/d/db"},
+		},
+		Infographics: config.InfographicsConfig{Renderer: config.InfographicsRendererNative},
+	}
+	infos := map[string]model.SubsystemInfo{
+		"front": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"api":   {Check: model.CheckResult{Status: model.PingStatusOk}},
+		"db": {
+			Check:              model.CheckResult{Status: model.PingStatusNotOk, Details: "connection refused"},
+			Metric:             model.MetricsExtractorResult{Metrics: []model.Metric{{Name: "connections", Value: 0, Series: []float64{40, 42, 0}}}},
+			SuspectedRootCause: true,
+		},
+	}
+	return cfg, infos
+}
+
+func TestLayout_DependenciesBelowDependents(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	l := newTestRenderer(t, cfg, 0).layout(infos)
+
+	front, _ := l.node("front")
+	api, _ := l.node("api")
+	db, _ := l.node("db")
+	if !(front.y < api.y && api.y < db.y) {
+		t.Fatalf("expected front above api above db; got front=%d api=%d db=%d", front.y, api.y, db.y)
+	}
+	if len(l.edges) != 2 {
+		t.Fatalf("expected 2 edges; got %v", l.edges)
+	}
+	if db.notes[0].text != "suspected root cause" {
+		t.Fatalf("expected root cause note for db; got %v", db.notes)
+	}
+}
+
+func TestRender_NativePNG(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	ir := newTestRenderer(t, cfg, 0)
+
+	infographic, err := ir.Render(context.Background(), infos, model.InfographicFormatPNG)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(infographic.Data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+
+	l := ir.layout(infos)
+	api, _ := l.node("api")
+	// Пиксель внутри узла, выше подписи
+	r, g, b, _ := img.At(api.x, api.y-nodeRadius/2).RGBA()
+	if got := fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8); got != statusToColor(model.PingStatusOk) {
+		t.Fatalf("expected api node filled with ok color; got %s", got)
+	}
+}
+
+func TestRender_NativeSVG(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	ir := newTestRenderer(t, cfg, 0)
+
+	infographic, err := ir.Render(context.Background(), infos, model.InfographicFormatSVG)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if infographic.ContentType != "image/svg+xml" {
+		t.Fatalf("unexpected content type %q", infographic.ContentType)
+	}
+	svg := string(infographic.Data)
+	for _, want := range []string{"<svg", `id="node-db"`, rootCauseColor, "suspected root cause", `marker-end="url(#arrow)"`,
+		"connection refused", "connections = 0", `class="sparkline"`,
+		`<a href="https://wiki.example.com/db" target="_blank">`, `<a href="https://grafana.example.com/d/api" target="_blank">`} {
+		if !strings.Contains(svg, want) {
+			t.Fatalf("svg missing %q: %s", want, svg)
+		}
+	}
+	if strings.Count(svg, "<line") != 2 {
+		t.Fatalf("expected 2 edges in svg: %s", svg)
+	}
+	if strings.Contains(svg, "javascript:") {
+		t.Fatalf("unsafe link must be dropped: %s", svg)
+	}
+}
+
+func TestRender_NativeSVG_EscapesNames(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			`a&b<"c">`: {Deps: []string{"db"}, RunbookURL: "https://wiki.example.com/?a=1&b=2"},
+			"db":       {},
+		},
+		Infographics: config.InfographicsConfig{Renderer: config.InfographicsRendererNative},
+	}
+	infos := map[string]model.SubsystemInfo{
+		`a&b<"c">`: {Check: model.CheckResult{Status: model.PingStatusNotOk, Details: `<script>"x"</script>`}},
+		"db":       {Check: model.CheckResult{Status: model.PingStatusOk}},
+	}
+
+	infographic, err := newTestRenderer(t, cfg, 0).Render(context.Background(), infos, model.InfographicFormatSVG)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	svg := string(infographic.Data)
+	if !strings.Contains(svg, `id="node-a&amp;b&lt;&#34;c&#34;&gt;"`) {
+		t.Fatalf("node id must be XML-escaped: %s", svg)
+	}
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("svg is not well-formed XML: %v\n%s", err, svg)
+		}
+	}
+}
+
+func TestRender_HTML(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	ir := newTestRenderer(t, cfg, 0)
+	ir.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	infographic, err := ir.Render(context.Background(), infos, model.InfographicFormatHTML)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if infographic.ContentType != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", infographic.ContentType)
+	}
+	page := string(infographic.Data)
+	for _, want := range []string{
+		"<title>pingr: 2 of 3 backends failing</title>",
+		"2025-03-01T12:00:00Z",
+		`<svg xmlns="http://www.w3.org/2000/svg"`,
+		`<tr id="backend-db">`,
+		"connection refused",
+		`<a href="https://wiki.example.com/db" target="_blank">runbook</a>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("html missing %q: %s", want, page)
+		}
+	}
+	// SVG встраивается как разметка, а не как текст
+	if strings.Contains(page, "&lt;svg") {
+		t.Fatalf("svg must not be escaped: %s", page)
+	}
+}
+
+func TestRender_UnsupportedFormat(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	if _, err := newTestRenderer(t, cfg, 0).Render(context.Background(), infos, "gif"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestBuildDOTFromConfig_Links(t *testing.T) {
+	cfg, infos := nativeTestGraph()
+	dot := newTestRenderer(t, cfg, 0).buildDOTFromConfig(infos)
+
+	if !strings.Contains(dot, `URL="https://wiki.example.com/db", target="_blank"`) {
+		t.Fatalf("expected runbook link on db; got: %s", dot)
+	}
+	if strings.Contains(dot, "javascript:") {
+		t.Fatalf("unsafe link must be dropped; got: %s", dot)
+	}
+}
+
+func TestRender_GraphvizSelection(t *testing.T) {
+	cfg, _ := nativeTestGraph()
+	missing := func(string) (string, error) { return "", exec.ErrNotFound }
+	found := func(string) (string, error) { return "/usr/bin/dot", nil }
+
+	for _, tt := range []struct {
+		renderer string
+		lookPath func(string) (string, error)
+		want     bool
+	}{
+		{config.InfographicsRendererAuto, missing, false},
+		{config.InfographicsRendererAuto, found, true},
+		{config.InfographicsRendererNative, found, false},
+		{config.InfographicsRendererGraphviz, missing, true},
+	} {
+		cfg.Infographics.Renderer = tt.renderer
+		ir := newTestRenderer(t, cfg, 0)
+		ir.lookPath = tt.lookPath
+		if got := ir.useGraphviz(); got != tt.want {
+			t.Errorf("renderer %s: useGraphviz() = %v, want %v", tt.renderer, got, tt.want)
+		}
+	}
+}
+
+func blastTestGraph() (config.Config, map[string]model.SubsystemInfo) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"front": {Deps: []string{"api", "auth"}},
+			"api":   {Deps: []string{"db", "cache"}},
+			"batch": {Deps: []string{"db"}},
+			"auth":  {Deps: []string{"ldap"}},
+			"db":    {},
+			"cache": {},
+			"ldap":  {},
+		},
+	}
+	infos := map[string]model.SubsystemInfo{
+		"front": {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"api":   {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"batch": {Check: model.CheckResult{Status: model.PingStatusOk}},
+		"auth":  {Check: model.CheckResult{Status: model.PingStatusOk}},
+		"db":    {Check: model.CheckResult{Status: model.PingStatusNotOk}},
+		"cache": {Check: model.CheckResult{Status: model.PingStatusOk}},
+		"ldap":  {Check: model.CheckResult{Status: model.PingStatusOk}},
+	}
+	return cfg, infos
+}
+
+func TestComputeBlastRadius(t *testing.T) {
+	cfg, infos := blastTestGraph()
+	blast := computeBlastRadius(cfg, infos)
+
+	if !blast.root("db") || blast.root("api") || blast.root("front") {
+		t.Fatalf("expected only db to be a root; got %v", blast.roots)
+	}
+	if !blast.impacted("api") || !blast.impacted("front") || blast.impacted("db") {
+		t.Fatalf("expected api and front impacted")
+	}
+	// batch здоров, но зависит от упавшей db и остаётся в радиусе
+	for _, name := range []string{"front", "api", "db", "batch"} {
+		if blast.dimmed(name) {
+			t.Fatalf("%s must not be dimmed", name)
+		}
+	}
+	for _, name := range []string{"auth", "cache", "ldap"} {
+		if !blast.dimmed(name) {
+			t.Fatalf("%s must be dimmed", name)
+		}
+	}
+	if !blast.propagation("api", "db") || !blast.propagation("front", "api") || blast.propagation("batch", "db") {
+		t.Fatalf("unexpected propagation edges")
+	}
+}
+
+func TestComputeBlastRadius_NoFailuresNothingDimmed(t *testing.T) {
+	cfg, infos := blastTestGraph()
+	for name, info := range infos {
+		info.Check.Status = model.PingStatusOk
+		infos[name] = info
+	}
+	blast := computeBlastRadius(cfg, infos)
+	for name := range cfg.Backends {
+		if blast.dimmed(name) {
+			t.Fatalf("%s must not be dimmed without failures", name)
+		}
+	}
+}
+
+func TestBuildDOTFromConfig_BlastRadius(t *testing.T) {
+	cfg, infos := blastTestGraph()
+	ir := newTestRenderer(t, cfg, 0)
+	ir.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	dot := ir.buildDOTFromConfig(infos)
+
+	for _, want := range []string{
+		`"api" -> "db" [color="#d62728", penwidth=3]`,
+		`"front" -> "api" [color="#d62728", penwidth=3]`,
+		`"auth" -> "ldap" [color="#cccccc"`,
+		`"batch" -> "db";`,
+		`fillcolor="` + impactedColor + `"`,
+		`fillcolor="` + dimColor(statusToColor(model.PingStatusOk)) + `", fontcolor="#999999"`,
+		"<B>Incident " + incident.Fingerprint(infos) + "</B>",
+		"2025-03-01 12:00:00 UTC, 3 of 7 backends failing",
+		"root cause: failing, dependencies are fine",
+		"failure propagation",
+		"outside the blast radius",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expected %q in dot; got: %s", want, dot)
+		}
+	}
+	if strings.Count(dot, "shape=doublecircle") != 1 {
+		t.Fatalf("expected only db outlined as root; got: %s", dot)
+	}
+	// Аномалий нет, значит и в легенде их нет
+	if strings.Contains(dot, "metric anomaly") {
+		t.Fatalf("legend must list only present elements; got: %s", dot)
+	}
+}
+
+func TestRender_NativeBlastRadius(t *testing.T) {
+	cfg, infos := blastTestGraph()
+	cfg.Infographics.Renderer = config.InfographicsRendererNative
+	ir := newTestRenderer(t, cfg, 0)
+
+	l := ir.layout(infos)
+	if len(l.header) != 2 || len(l.legend) != 5 {
+		t.Fatalf("expected header and legend; got %v %v", l.header, l.legend)
+	}
+	front, _ := l.node("front")
+	if front.y-nodeRadius < canvasPadding+l.headerHeight() {
+		t.Fatalf("graph overlaps the header: front at %d, header height %d", front.y, l.headerHeight())
+	}
+
+	infographic, err := ir.Render(context.Background(), infos, model.InfographicFormatSVG)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	svg := string(infographic.Data)
+	for _, want := range []string{`marker-end="url(#arrow-d62728)"`, `class="header"`, `class="legend"`, dimmedTextColor} {
+		if !strings.Contains(svg, want) {
+			t.Fatalf("svg missing %q: %s", want, svg)
+		}
+	}
+}
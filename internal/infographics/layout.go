@@ -0,0 +1,278 @@
+package infographics
+
+import (
+	"sort"
+
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+// Геометрия нативного рендера в пикселях
+const (
+	nodeRadius    = 36
+	canvasPadding = 32
+	nodeGapX      = 40
+	rankGapY      = 64
+	// Высота строки и ширина символа basicfont.Face7x13
+	lineHeight = 14
+	charWidth  = 7
+	// Квадрат цвета в легенде и отступ до подписи
+	swatchSize = 12
+	swatchGap  = 6
+	// Sparkline рисуется справа от подписи метрики
+	sparklineWidth  = 48
+	sparklineHeight = 10
+	sparklineGap    = 6
+)
+
+type layoutNode struct {
+	name   string
+	x, y   int
+	status model.PingStatus
+	fill   string
+	// Подписи под узлом: отметка первопричины, детали проверки и ключевые метрики
+	notes     []detailLine
+	anomalous bool
+	// Корень радиуса поражения, обводится двойной рамкой
+	root bool
+	// Вне радиуса поражения, рисуется бледным
+	dimmed bool
+	// Куда ведёт клик по узлу в SVG
+	link string
+}
+
+func (n layoutNode) outerRadius() float64 {
+	if n.root {
+		return nodeRadius + rootCauseOutline
+	}
+	return nodeRadius
+}
+
+type layoutEdge struct {
+	from, to string
+	color    string
+	width    float64
+}
+
+type graphLayout struct {
+	width, height int
+	// Заголовок с id инцидента и временем, под ним легенда
+	header []string
+	legend []legendEntry
+	nodes  []layoutNode
+	index  map[string]int
+	edges  []layoutEdge
+}
+
+func (l *graphLayout) node(name string) (layoutNode, bool) {
+	i, ok := l.index[name]
+	if !ok {
+		return layoutNode{}, false
+	}
+	return l.nodes[i], true
+}
+
+// layout places backends in ranks by the same depth computation as the DOT
+// output: dependents on top, their dependencies below. Inside a rank nodes are
+// ordered by the barycenter of their neighbours to reduce edge crossings.
+func (ir *ImageRenderer) layout(infos map[string]model.SubsystemInfo) *graphLayout {
+	depths, maxDepth := computeDepths(ir.cfg)
+	blast := computeBlastRadius(ir.cfg, infos)
+
+	ranks := make([][]string, maxDepth+1)
+	for name := range ir.cfg.Backends {
+		rankIdx := maxDepth - depths[name]
+		ranks[rankIdx] = append(ranks[rankIdx], name)
+	}
+	for _, rank := range ranks {
+		sort.Strings(rank)
+	}
+
+	var edges []layoutEdge
+	neighbours := make(map[string][]string)
+	names := make([]string, 0, len(ir.cfg.Backends))
+	for name := range ir.cfg.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, dep := range ir.cfg.Backends[name].Deps {
+			if _, ok := ir.cfg.Backends[dep]; !ok || dep == name {
+				continue
+			}
+			color, width := blast.edgeStyle(name, dep)
+			edges = append(edges, layoutEdge{from: name, to: dep, color: color, width: width})
+			neighbours[name] = append(neighbours[name], dep)
+			neighbours[dep] = append(neighbours[dep], name)
+		}
+	}
+
+	orderRanks(ranks, neighbours)
+
+	l := &graphLayout{
+		header: header(ir.cfg, infos, ir.now()),
+		legend: blast.legend(ir.cfg, infos),
+		index:  make(map[string]int),
+		edges:  edges,
+	}
+
+	// Ширина слота определяется самой длинной подписью, чтобы подписи соседей не пересекались
+	slotWidth := 2*nodeRadius + nodeGapX
+	notesLines := 0
+	nodes := make(map[string]layoutNode, len(names))
+	for _, name := range names {
+		info := infos[name]
+		n := layoutNode{
+			name:      name,
+			status:    info.Check.Status,
+			fill:      blast.fillColor(name, info.Check.Status),
+			notes:     nodeDetails(info, ir.redactor),
+			anomalous: hasAnomaly(info),
+			root:      blast.root(name),
+			dimmed:    blast.dimmed(name),
+			link:      nodeLink(ir.cfg.Backends[name]),
+		}
+		for _, note := range append([]detailLine{{text: name}}, n.notes...) {
+			if w := note.width() + nodeGapX/2; w > slotWidth {
+				slotWidth = w
+			}
+		}
+		if len(n.notes) > notesLines {
+			notesLines = len(n.notes)
+		}
+		nodes[name] = n
+	}
+
+	widest := 0
+	for _, rank := range ranks {
+		if len(rank) > widest {
+			widest = len(rank)
+		}
+	}
+
+	headerWidth := 0
+	for _, line := range l.header {
+		headerWidth = max(headerWidth, textWidth(asciiText(line)))
+	}
+	for _, entry := range l.legend {
+		headerWidth = max(headerWidth, swatchSize+swatchGap+textWidth(asciiText(entry.text)))
+	}
+	headerHeight := l.headerHeight()
+
+	rankHeight := 2*nodeRadius + rankGapY + notesLines*lineHeight
+	graphWidth := max(widest, 1) * slotWidth
+	l.width = 2*canvasPadding + max(graphWidth, headerWidth)
+	l.height = 2*canvasPadding + headerHeight + max(len(ranks), 1)*rankHeight - rankGapY
+
+	for rankIdx, rank := range ranks {
+		offset := canvasPadding + (l.width-2*canvasPadding-len(rank)*slotWidth)/2
+		for i, name := range rank {
+			n := nodes[name]
+			n.x = offset + i*slotWidth + slotWidth/2
+			n.y = canvasPadding + headerHeight + rankIdx*rankHeight + nodeRadius
+			l.index[name] = len(l.nodes)
+			l.nodes = append(l.nodes, n)
+		}
+	}
+
+	return l
+}
+
+// headerHeight is the space above the graph taken by the header and the legend.
+func (l *graphLayout) headerHeight() int {
+	lines := len(l.header) + len(l.legend)
+	if lines == 0 {
+		return 0
+	}
+	return lines*lineHeight + rankGapY/2
+}
+
+// headerLineY is the text baseline of the i-th line of the header block.
+func headerLineY(i int) int {
+	return canvasPadding + (i+1)*lineHeight - 3
+}
+
+// orderRanks sorts each rank by the mean position of neighbours in the
+// adjacent rank, sweeping down and then up.
+func orderRanks(ranks [][]string, neighbours map[string][]string) {
+	position := make(map[string]float64)
+	remember := func(rank []string) {
+		for i, name := range rank {
+			position[name] = float64(i) - float64(len(rank)-1)/2
+		}
+	}
+	reorder := func(rank []string, fixed []string) {
+		inFixed := make(map[string]bool, len(fixed))
+		for _, name := range fixed {
+			inFixed[name] = true
+		}
+		bary := make(map[string]float64, len(rank))
+		for _, name := range rank {
+			var sum float64
+			var count int
+			for _, nb := range neighbours[name] {
+				if inFixed[nb] {
+					sum += position[nb]
+					count++
+				}
+			}
+			if count == 0 {
+				bary[name] = position[name]
+			} else {
+				bary[name] = sum / float64(count)
+			}
+		}
+		sort.SliceStable(rank, func(i, j int) bool {
+			return bary[rank[i]] < bary[rank[j]]
+		})
+		remember(rank)
+	}
+
+	for _, rank := range ranks {
+		remember(rank)
+	}
+	for i := 1; i < len(ranks); i++ {
+		reorder(ranks[i], ranks[i-1])
+	}
+	for i := len(ranks) - 2; i >= 0; i-- {
+		reorder(ranks[i], ranks[i+1])
+	}
+}
+
+// width is the rendered width of the line. The ASCII variant is measured
+// because it is never shorter than the original text.
+func (d detailLine) width() int {
+	w := textWidth(asciiText(d.text))
+	if len(d.series) > 1 {
+		w += sparklineGap + sparklineWidth
+	}
+	return w
+}
+
+// sparklinePath places the series into the sparkline box that starts at x and
+// sits on the text baseline.
+func sparklinePath(series []float64, x, baseline float64) [][2]float64 {
+	values := normalize(resample(series, sparklinePoints))
+	if len(values) < 2 {
+		return nil
+	}
+	path := make([][2]float64, len(values))
+	for i, v := range values {
+		path[i] = [2]float64{
+			x + float64(i)*sparklineWidth/float64(len(values)-1),
+			baseline - v*sparklineHeight,
+		}
+	}
+	return path
+}
+
+func textWidth(s string) int {
+	return len([]rune(s)) * charWidth
+}
+
+// fitLabel shortens a label to fit into maxWidth pixels.
+func fitLabel(s string, maxWidth int) string {
+	if maxChars := maxWidth / charWidth; maxChars >= 3 {
+		return truncate(s, maxChars)
+	}
+	return s
+}
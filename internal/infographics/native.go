@@ -0,0 +1,268 @@
+package infographics
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	edgeColor  = "#333333"
+	textColor  = "#1a1a1a"
+	arrowSize  = 10.0
+	edgeWidth  = 1.5
+	sparkColor = "#1f5fa8"
+	sparkWidth = 1.2
+	noteIndent = 4
+	// Вторая окружность первопричины рисуется снаружи, чтобы не закрывать подпись
+	rootCauseOutline = 6
+)
+
+// basicfont содержит только ASCII, остальное заменяется
+var asciiReplacer = strings.NewReplacer("σ", " sigma", "…", "..", "–", "-", "—", "-")
+
+func asciiText(s string) string {
+	s = asciiReplacer.Replace(s)
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// renderNativePNG rasterizes the layout without external tools.
+func renderNativePNG(l *graphLayout) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, l.width, l.height))
+	fillRect(img, img.Bounds(), color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+
+	face := basicfont.Face7x13
+	drawHeader(img, face, l)
+
+	// Рёбра пути отказа рисуются последними, чтобы их не перекрывали бледные
+	edges := append([]layoutEdge(nil), l.edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		return edges[i].width < edges[j].width
+	})
+	for _, e := range edges {
+		from, _ := l.node(e.from)
+		to, _ := l.node(e.to)
+		x1, y1, x2, y2, ok := edgeEndpoints(from, to)
+		if !ok {
+			continue
+		}
+		// Линия заканчивается у основания стрелки, чтобы не торчать из-под неё
+		length := math.Hypot(x2-x1, y2-y1)
+		bx, by := x2-(x2-x1)/length*arrowSize, y2-(y2-y1)/length*arrowSize
+		edge := parseHexColor(e.color)
+		drawLine(img, x1, y1, bx, by, e.width, edge)
+		drawArrowHead(img, x1, y1, x2, y2, edge)
+	}
+
+	for _, n := range l.nodes {
+		cx, cy := float64(n.x), float64(n.y)
+		drawDisc(img, cx, cy, nodeRadius, parseHexColor(n.fill))
+
+		text := parseHexColor(textColor)
+		if n.dimmed {
+			text = parseHexColor(dimmedTextColor)
+		}
+
+		switch {
+		case n.root:
+			drawRing(img, cx, cy, nodeRadius, 3, parseHexColor(rootCauseColor))
+			drawRing(img, cx, cy, nodeRadius+rootCauseOutline, 3, parseHexColor(rootCauseColor))
+		case n.anomalous:
+			drawRing(img, cx, cy, nodeRadius, 3, parseHexColor(anomalyColor))
+		}
+
+		label := asciiText(fitLabel(n.name, 2*nodeRadius-8))
+		drawText(img, face, label, n.x-textWidth(label)/2, n.y+4, text)
+
+		for i, note := range n.notes {
+			x := n.x - note.width()/2
+			y := n.y + nodeRadius + noteIndent + (i+1)*lineHeight
+			label := asciiText(note.text)
+			drawText(img, face, label, x, y, text)
+
+			path := sparklinePath(note.series, float64(x+textWidth(label)+sparklineGap), float64(y))
+			for j := 1; j < len(path); j++ {
+				drawLine(img, path[j-1][0], path[j-1][1], path[j][0], path[j][1], sparkWidth, parseHexColor(sparkColor))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawHeader draws the incident header and the legend above the graph.
+func drawHeader(img *image.RGBA, face font.Face, l *graphLayout) {
+	text := parseHexColor(textColor)
+	for i, line := range l.header {
+		drawText(img, face, asciiText(line), canvasPadding, headerLineY(i), text)
+	}
+
+	for i, entry := range l.legend {
+		y := headerLineY(len(l.header) + i)
+		cx, cy := float64(canvasPadding)+swatchSize/2, float64(y)-swatchSize/2+1
+		switch entry.kind {
+		case legendEdge:
+			drawLine(img, canvasPadding, cy, canvasPadding+swatchSize, cy, 3, parseHexColor(entry.color))
+		case legendRing:
+			drawDisc(img, cx, cy, swatchSize/2, parseHexColor(entry.fill))
+			drawRing(img, cx, cy, swatchSize/2, 2, parseHexColor(entry.color))
+		default:
+			drawDisc(img, cx, cy, swatchSize/2, parseHexColor(entry.color))
+		}
+		drawText(img, face, asciiText(entry.text), canvasPadding+swatchSize+swatchGap, y, text)
+	}
+}
+
+// edgeEndpoints clips the segment between node centers to the node outlines.
+func edgeEndpoints(from, to layoutNode) (x1, y1, x2, y2 float64, ok bool) {
+	dx, dy := float64(to.x-from.x), float64(to.y-from.y)
+	length := math.Hypot(dx, dy)
+	if length <= from.outerRadius()+to.outerRadius() {
+		return 0, 0, 0, 0, false
+	}
+	ux, uy := dx/length, dy/length
+	return float64(from.x) + ux*from.outerRadius(), float64(from.y) + uy*from.outerRadius(),
+		float64(to.x) - ux*to.outerRadius(), float64(to.y) - uy*to.outerRadius(), true
+}
+
+func parseHexColor(s string) color.RGBA {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{A: 0xff}
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// blend mixes c into the pixel with the given coverage in [0, 1].
+func blend(img *image.RGBA, x, y int, c color.RGBA, coverage float64) {
+	if coverage <= 0 || !(image.Point{X: x, Y: y}).In(img.Bounds()) {
+		return
+	}
+	if coverage > 1 {
+		coverage = 1
+	}
+	dst := img.RGBAAt(x, y)
+	mix := func(d, s uint8) uint8 {
+		return uint8(math.Round(float64(d)*(1-coverage) + float64(s)*coverage))
+	}
+	img.SetRGBA(x, y, color.RGBA{R: mix(dst.R, c.R), G: mix(dst.G, c.G), B: mix(dst.B, c.B), A: 0xff})
+}
+
+// Фигуры рисуются по расстоянию от центра пикселя до контура, что даёт
+// сглаживание без суперсэмплинга.
+
+func drawDisc(img *image.RGBA, cx, cy, r float64, c color.RGBA) {
+	for y := int(cy - r - 1); y <= int(cy+r+1); y++ {
+		for x := int(cx - r - 1); x <= int(cx+r+1); x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			blend(img, x, y, c, r+0.5-d)
+		}
+	}
+}
+
+// drawRing draws a ring of the given width inside the circle of radius r.
+func drawRing(img *image.RGBA, cx, cy, r, width float64, c color.RGBA) {
+	for y := int(cy - r - 1); y <= int(cy+r+1); y++ {
+		for x := int(cx - r - 1); x <= int(cx+r+1); x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			blend(img, x, y, c, math.Min(r+0.5-d, d-(r-width)+0.5))
+		}
+	}
+}
+
+func drawLine(img *image.RGBA, x1, y1, x2, y2, width float64, c color.RGBA) {
+	minX, maxX := math.Min(x1, x2)-width, math.Max(x1, x2)+width
+	minY, maxY := math.Min(y1, y2)-width, math.Max(y1, y2)+width
+	for y := int(minY); y <= int(maxY); y++ {
+		for x := int(minX); x <= int(maxX); x++ {
+			d := distToSegment(float64(x)+0.5, float64(y)+0.5, x1, y1, x2, y2)
+			blend(img, x, y, c, width/2+0.5-d)
+		}
+	}
+}
+
+func drawArrowHead(img *image.RGBA, x1, y1, x2, y2 float64, c color.RGBA) {
+	length := math.Hypot(x2-x1, y2-y1)
+	ux, uy := (x2-x1)/length, (y2-y1)/length
+	bx, by := x2-ux*arrowSize, y2-uy*arrowSize
+	half := arrowSize / 2
+	ax, ay := bx-uy*half, by+ux*half
+	cx, cy := bx+uy*half, by-ux*half
+
+	minX, maxX := math.Min(x2, math.Min(ax, cx))-1, math.Max(x2, math.Max(ax, cx))+1
+	minY, maxY := math.Min(y2, math.Min(ay, cy))-1, math.Max(y2, math.Max(ay, cy))+1
+	for y := int(minY); y <= int(maxY); y++ {
+		for x := int(minX); x <= int(maxX); x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			inside := sameSide(px, py, x2, y2, ax, ay, cx, cy) &&
+				sameSide(px, py, ax, ay, cx, cy, x2, y2) &&
+				sameSide(px, py, cx, cy, x2, y2, ax, ay)
+			if inside {
+				blend(img, x, y, c, 1)
+				continue
+			}
+			d := math.Min(distToSegment(px, py, x2, y2, ax, ay),
+				math.Min(distToSegment(px, py, ax, ay, cx, cy), distToSegment(px, py, cx, cy, x2, y2)))
+			blend(img, x, y, c, 0.5-d)
+		}
+	}
+}
+
+// sameSide reports whether p lies on the same side of line ab as c.
+func sameSide(px, py, ax, ay, bx, by, cx, cy float64) bool {
+	cross := func(x, y float64) float64 {
+		return (bx-ax)*(y-ay) - (by-ay)*(x-ax)
+	}
+	return cross(px, py)*cross(cx, cy) >= 0
+}
+
+func distToSegment(px, py, x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return math.Hypot(px-x1, py-y1)
+	}
+	t := math.Max(0, math.Min(1, ((px-x1)*dx+(py-y1)*dy)/lengthSq))
+	return math.Hypot(px-(x1+t*dx), py-(y1+t*dy))
+}
+
+// drawText draws s with its baseline at y.
+func drawText(img *image.RGBA, face font.Face, s string, x, y int, c color.RGBA) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
@@ -0,0 +1,133 @@
+package infographics
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// renderNativeSVG writes the same picture as renderNativePNG as SVG.
+func renderNativeSVG(l *graphLayout) []byte {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="DejaVu Sans, sans-serif" font-size="12">`+"\n",
+		l.width, l.height, l.width, l.height))
+	b.WriteString("<defs>")
+	for _, color := range []string{edgeColor, propagationColor, dimmedEdgeColor} {
+		b.WriteString(fmt.Sprintf(
+			`<marker id="%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="%g" markerHeight="%g" markerUnits="userSpaceOnUse" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker>`,
+			markerID(color), arrowSize, arrowSize, color))
+	}
+	b.WriteString("</defs>\n")
+	b.WriteString(`<rect width="100%" height="100%" fill="white"/>` + "\n")
+
+	writeSVGHeader(&b, l)
+
+	// Рёбра пути отказа рисуются последними, чтобы их не перекрывали бледные
+	edges := append([]layoutEdge(nil), l.edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		return edges[i].width < edges[j].width
+	})
+	for _, e := range edges {
+		from, _ := l.node(e.from)
+		to, _ := l.node(e.to)
+		x1, y1, x2, y2, ok := edgeEndpoints(from, to)
+		if !ok {
+			continue
+		}
+		b.WriteString(fmt.Sprintf(
+			`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%g" marker-end="url(#%s)"/>`+"\n",
+			x1, y1, x2, y2, e.color, e.width, markerID(e.color)))
+	}
+
+	for _, n := range l.nodes {
+		if n.link != "" {
+			b.WriteString(fmt.Sprintf(`<a href="%s" target="_blank">`+"\n", html.EscapeString(n.link)))
+		}
+		b.WriteString(fmt.Sprintf(`<g class="node" id="%s">`+"\n", html.EscapeString("node-"+n.name)))
+		b.WriteString(fmt.Sprintf(`<title>%s</title>`+"\n", html.EscapeString(n.name)))
+		b.WriteString(fmt.Sprintf(`<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n",
+			n.x, n.y, nodeRadius, n.fill))
+
+		text := textColor
+		if n.dimmed {
+			text = dimmedTextColor
+		}
+
+		switch {
+		case n.root:
+			b.WriteString(fmt.Sprintf(`<circle cx="%d" cy="%d" r="%.1f" fill="none" stroke="%s" stroke-width="3"/>`+"\n",
+				n.x, n.y, nodeRadius-1.5, rootCauseColor))
+			b.WriteString(fmt.Sprintf(`<circle cx="%d" cy="%d" r="%.1f" fill="none" stroke="%s" stroke-width="3"/>`+"\n",
+				n.x, n.y, nodeRadius+rootCauseOutline-1.5, rootCauseColor))
+		case n.anomalous:
+			b.WriteString(fmt.Sprintf(`<circle cx="%d" cy="%d" r="%d" fill="none" stroke="%s" stroke-width="3"/>`+"\n",
+				n.x, n.y, nodeRadius-1, anomalyColor))
+		}
+
+		b.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" fill="%s">%s</text>`+"\n",
+			n.x, n.y+4, text, html.EscapeString(fitLabel(n.name, 2*nodeRadius-8))))
+		for i, note := range n.notes {
+			x := n.x - note.width()/2
+			y := n.y + nodeRadius + noteIndent + (i+1)*lineHeight
+			b.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="11" fill="%s">%s</text>`+"\n",
+				x, y, text, html.EscapeString(note.text)))
+
+			path := sparklinePath(note.series, float64(x+textWidth(asciiText(note.text))+sparklineGap), float64(y))
+			if len(path) == 0 {
+				continue
+			}
+			points := make([]string, len(path))
+			for j, p := range path {
+				points[j] = fmt.Sprintf("%.1f,%.1f", p[0], p[1])
+			}
+			b.WriteString(fmt.Sprintf(`<polyline class="sparkline" points="%s" fill="none" stroke="%s" stroke-width="%g"/>`+"\n",
+				strings.Join(points, " "), sparkColor, sparkWidth))
+		}
+		b.WriteString("</g>\n")
+		if n.link != "" {
+			b.WriteString("</a>\n")
+		}
+	}
+
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+func writeSVGHeader(b *strings.Builder, l *graphLayout) {
+	for i, line := range l.header {
+		weight := "normal"
+		if i == 0 {
+			weight = "bold"
+		}
+		b.WriteString(fmt.Sprintf(`<text class="header" x="%d" y="%d" font-weight="%s" fill="%s">%s</text>`+"\n",
+			canvasPadding, headerLineY(i), weight, textColor, html.EscapeString(line)))
+	}
+
+	for i, entry := range l.legend {
+		y := headerLineY(len(l.header) + i)
+		cx, cy := float64(canvasPadding)+swatchSize/2, float64(y)-swatchSize/2+1
+		b.WriteString(`<g class="legend">`)
+		switch entry.kind {
+		case legendEdge:
+			b.WriteString(fmt.Sprintf(`<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s" stroke-width="3"/>`,
+				canvasPadding, cy, canvasPadding+swatchSize, cy, entry.color))
+		case legendRing:
+			b.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%d" fill="%s" stroke="%s" stroke-width="2"/>`,
+				cx, cy, swatchSize/2-1, entry.fill, entry.color))
+		default:
+			b.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%d" fill="%s"/>`, cx, cy, swatchSize/2, entry.color))
+		}
+		b.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="11" fill="%s">%s</text></g>`+"\n",
+			canvasPadding+swatchSize+swatchGap, y, textColor, html.EscapeString(entry.text)))
+	}
+}
+
+// markerID names the arrow marker of the edge color; the default one is "arrow".
+func markerID(color string) string {
+	if color == edgeColor {
+		return "arrow"
+	}
+	return "arrow-" + strings.TrimPrefix(color, "#")
+}
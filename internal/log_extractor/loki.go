@@ -0,0 +1,193 @@
+package log_extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/querytemplate"
+	"github.com/unicoooorn/pingr/internal/service"
+)
+
+// Статическая проверка на то что структура соответствует интерфейсу
+var _ service.LogExtractor = &LokiLogExtractor{}
+
+const (
+	defaultLokiLimit    = 20
+	defaultLokiLookback = 15 * time.Minute
+	defaultLokiTimeout  = 10 * time.Second
+)
+
+type LokiLogExtractor struct {
+	url      string
+	headers  map[string]string
+	limit    int
+	lookback time.Duration
+	client   *http.Client
+	backends map[string]config.BackendConfig
+}
+
+func NewLokiLogExtractor(cfg config.LokiConfig) (*LokiLogExtractor, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("loki url not configured")
+	}
+
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	if auth := cfg.Auth.AuthorizationHeader(); auth != "" {
+		headers["Authorization"] = auth
+	}
+
+	limit := cfg.Limit
+	if limit <= 0 {
+		limit = defaultLokiLimit
+	}
+	lookback := cfg.Lookback
+	if lookback <= 0 {
+		lookback = defaultLokiLookback
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultLokiTimeout
+	}
+
+	return &LokiLogExtractor{
+		url:      strings.TrimRight(cfg.URL, "/"),
+		headers:  headers,
+		limit:    limit,
+		lookback: lookback,
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// WithBackends provides backend configs used as template data when rendering
+// LogQL queries, the same way as for metrics queries.
+func (l *LokiLogExtractor) WithBackends(backends map[string]config.BackendConfig) *LokiLogExtractor {
+	l.backends = backends
+	return l
+}
+
+type lokiResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// Ping lists the labels to check that Loki answers with the configured credentials.
+func (l *LokiLogExtractor) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url+"/loki/api/v1/labels", nil)
+	if err != nil {
+		return fmt.Errorf("create request to loki: %w", err)
+	}
+	for k, v := range l.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request to loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("loki returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (l *LokiLogExtractor) Extract(ctx context.Context, backend string, query string) (model.LogExtractorResult, error) {
+	if query == "" {
+		return model.LogExtractorResult{Details: "no log query configured"}, nil
+	}
+
+	logQL, err := querytemplate.Render(query, querytemplate.NewVars(backend, l.backends[backend]))
+	if err != nil {
+		return model.LogExtractorResult{}, fmt.Errorf("render log query: %w", err)
+	}
+
+	now := time.Now()
+	params := url.Values{}
+	params.Set("query", logQL)
+	params.Set("limit", strconv.Itoa(l.limit))
+	params.Set("direction", "backward")
+	params.Set("start", strconv.FormatInt(now.Add(-l.lookback).UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(now.UnixNano(), 10))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return model.LogExtractorResult{}, fmt.Errorf("create request to loki: %w", err)
+	}
+	for k, v := range l.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return model.LogExtractorResult{}, fmt.Errorf("do request to loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return model.LogExtractorResult{}, fmt.Errorf("loki returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed lokiResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return model.LogExtractorResult{}, fmt.Errorf("decode loki response: %w", err)
+	}
+	if parsed.Status != "success" {
+		return model.LogExtractorResult{}, fmt.Errorf("loki query failed: %s", parsed.Error)
+	}
+
+	lines := convertToLines(parsed)
+	if len(lines) > l.limit {
+		lines = lines[:l.limit]
+	}
+
+	return model.LogExtractorResult{
+		Lines:   lines,
+		Details: fmt.Sprintf("extracted %d log lines", len(lines)),
+	}, nil
+}
+
+// convertToLines flattens streams into lines ordered from newest to oldest.
+func convertToLines(resp lokiResponse) []model.LogLine {
+	var lines []model.LogLine
+	for _, stream := range resp.Data.Result {
+		for _, value := range stream.Values {
+			ns, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			lines = append(lines, model.LogLine{
+				Timestamp: time.Unix(0, ns),
+				Line:      value[1],
+				Labels:    stream.Stream,
+			})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Timestamp.After(lines[j].Timestamp)
+	})
+	return lines
+}
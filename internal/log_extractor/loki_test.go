@@ -0,0 +1,119 @@
+package log_extractor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
+func TestLokiLogExtractor_Extract(t *testing.T) {
+	var received *http.Request
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		if r.URL.Path != "/loki/api/v1/query_range" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "streams",
+				"result": []map[string]interface{}{
+					{
+						"stream": map[string]string{"job": "api", "pod": "api-1"},
+						"values": [][]string{
+							{"1700000003000000000", "error: db timeout"},
+							{"1700000001000000000", "error: connection refused"},
+						},
+					},
+					{
+						"stream": map[string]string{"job": "api", "pod": "api-2"},
+						"values": [][]string{
+							{"1700000002000000000", "error: pool exhausted"},
+						},
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewLokiLogExtractor(config.LokiConfig{
+		URL:   mockServer.URL,
+		Limit: 2,
+		Auth:  config.AuthConfig{BearerToken: "token"},
+	})
+	require.NoError(t, err)
+
+	result, err := extractor.Extract(context.Background(), "api", `{job="{{.Backend}}"} |= "error"`)
+	require.NoError(t, err)
+
+	require.NotNil(t, received)
+	assert.Equal(t, `{job="api"} |= "error"`, received.URL.Query().Get("query"))
+	assert.Equal(t, "2", received.URL.Query().Get("limit"))
+	assert.Equal(t, "backward", received.URL.Query().Get("direction"))
+	assert.Equal(t, "Bearer token", received.Header.Get("Authorization"))
+
+	// Строки из разных потоков сливаются от новых к старым и обрезаются по лимиту
+	require.Len(t, result.Lines, 2)
+	assert.Equal(t, "error: db timeout", result.Lines[0].Line)
+	assert.Equal(t, "error: pool exhausted", result.Lines[1].Line)
+	assert.Equal(t, "api-2", result.Lines[1].Labels["pod"])
+	assert.Equal(t, int64(1700000002), result.Lines[1].Timestamp.Unix())
+}
+
+func TestLokiLogExtractor_Extract_Non200(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error", http.StatusBadRequest)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewLokiLogExtractor(config.LokiConfig{URL: mockServer.URL})
+	require.NoError(t, err)
+
+	_, err = extractor.Extract(context.Background(), "api", `{job=`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "loki returned status 400")
+}
+
+func TestNewLokiLogExtractor_NotConfigured(t *testing.T) {
+	_, err := NewLokiLogExtractor(config.LokiConfig{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "loki url not configured")
+}
+
+func TestLokiLogExtractor_Ping(t *testing.T) {
+	var authorization string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Path != "/loki/api/v1/labels" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":["job"]}`))
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewLokiLogExtractor(config.LokiConfig{
+		URL:  mockServer.URL,
+		Auth: config.AuthConfig{BearerToken: "loki-token"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, extractor.Ping(context.Background()))
+	assert.Equal(t, "Bearer loki-token", authorization)
+
+	extractor, err = NewLokiLogExtractor(config.LokiConfig{URL: mockServer.URL + "/nope"})
+	require.NoError(t, err)
+	err = extractor.Ping(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "loki returned status 404")
+}
@@ -9,14 +9,13 @@ import (
 )
 
 func ExtractMetrics(ctx context.Context, cfg config.Config, subsystem string) (model.MetricsExtractorResult, error) {
-	backendCfg, ok := cfg.Backends[subsystem]
-	if !ok {
+	if _, ok := cfg.Backends[subsystem]; !ok {
 		return model.MetricsExtractorResult{}, fmt.Errorf("backend config for subsystem %q not found", subsystem)
 	}
 
-	extractor, err := NewPrometheusMetricsExtractor(cfg.Prometheus)
+	extractor, err := NewRouter(cfg)
 	if err != nil {
 		return model.MetricsExtractorResult{}, fmt.Errorf("failed to create metrics extractor: %w", err)
 	}
-	return extractor.Extract(ctx, subsystem, backendCfg.MetricsQueries)
+	return extractor.Extract(ctx, subsystem, cfg.MetricsQueriesFor(subsystem))
 }
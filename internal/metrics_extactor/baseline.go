@@ -0,0 +1,186 @@
+package metrics_extractor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/unicoooorn/pingr/internal/config"
+	internalModel "github.com/unicoooorn/pingr/internal/model"
+)
+
+const (
+	defaultBaselineLookback  = time.Hour
+	defaultBaselineStep      = time.Minute
+	defaultStdDevThreshold   = 3.0
+	defaultLastWeekThreshold = 0.5
+	lastWeekOffset           = 7 * 24 * time.Hour
+	// Изменение меньше этой доли от значения (или от 1 около нуля) считается
+	// шумом: ровный baseline иначе ловит любую разницу в последнем знаке
+	minSignificantChange = 0.01
+)
+
+// annotateBaseline fills Metric.Baseline for every metric that has a matching
+// series in the baseline window.
+func (p *PrometheusMetricsExtractor) annotateBaseline(ctx context.Context, query string, now time.Time, metrics []internalModel.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	switch p.baseline.Mode {
+	case config.BaselineModeStdDev:
+		return p.annotateStdDev(ctx, query, now, metrics)
+	case config.BaselineModeLastWeek:
+		return p.annotateLastWeek(ctx, query, now, metrics)
+	default:
+		return fmt.Errorf("unknown baseline mode %q", p.baseline.Mode)
+	}
+}
+
+func (p *PrometheusMetricsExtractor) annotateStdDev(ctx context.Context, query string, now time.Time, metrics []internalModel.Metric) error {
+	lookback := p.baseline.Lookback
+	if lookback == 0 {
+		lookback = defaultBaselineLookback
+	}
+	step := p.baseline.Step
+	if step == 0 {
+		step = defaultBaselineStep
+	}
+	threshold := p.baseline.Threshold
+	if threshold == 0 {
+		threshold = defaultStdDevThreshold
+	}
+
+	// Текущая точка не входит в окно, чтобы не размывать baseline самой аномалией
+	value, _, err := p.api.QueryRange(ctx, query, v1.Range{
+		Start: now.Add(-lookback),
+		End:   now.Add(-step),
+		Step:  step,
+	})
+	if err != nil {
+		return err
+	}
+
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return nil
+	}
+
+	windows := make(map[string][]float64, len(matrix))
+	for _, stream := range matrix {
+		name, labels := splitMetric(stream.Metric)
+		values := make([]float64, 0, len(stream.Values))
+		for _, pair := range stream.Values {
+			values = append(values, float64(pair.Value))
+		}
+		windows[seriesKey(name, labels)] = values
+	}
+
+	for i := range metrics {
+		window, ok := windows[seriesKey(metrics[i].Name, metrics[i].Labels)]
+		if !ok || len(window) == 0 {
+			continue
+		}
+		mean, stddev := meanStdDev(window)
+		delta := metrics[i].Value - mean
+
+		b := &internalModel.MetricBaseline{
+			Mode:   config.BaselineModeStdDev,
+			Mean:   mean,
+			StdDev: stddev,
+			Delta:  delta,
+		}
+		if stddev == 0 {
+			b.Anomalous = significant(delta, mean)
+		} else {
+			b.Score = delta / stddev
+			b.Anomalous = math.Abs(b.Score) >= threshold && significant(delta, mean)
+		}
+		metrics[i].Baseline = b
+	}
+
+	return nil
+}
+
+func (p *PrometheusMetricsExtractor) annotateLastWeek(ctx context.Context, query string, now time.Time, metrics []internalModel.Metric) error {
+	threshold := p.baseline.Threshold
+	if threshold == 0 {
+		threshold = defaultLastWeekThreshold
+	}
+
+	value, _, err := p.api.Query(ctx, query, now.Add(-lastWeekOffset))
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]float64)
+	for _, m := range convertToMetrics(value) {
+		previous[seriesKey(m.Name, m.Labels)] = m.Value
+	}
+
+	for i := range metrics {
+		prev, ok := previous[seriesKey(metrics[i].Name, metrics[i].Labels)]
+		if !ok {
+			continue
+		}
+		delta := metrics[i].Value - prev
+
+		b := &internalModel.MetricBaseline{
+			Mode:  config.BaselineModeLastWeek,
+			Mean:  prev,
+			Delta: delta,
+		}
+		if prev == 0 {
+			b.Anomalous = significant(delta, prev)
+		} else {
+			b.Score = delta / math.Abs(prev)
+			b.Anomalous = math.Abs(b.Score) >= threshold
+		}
+		metrics[i].Baseline = b
+	}
+
+	return nil
+}
+
+// significant reports whether delta is more than noise relative to base.
+func significant(delta, base float64) bool {
+	return math.Abs(delta) > minSignificantChange*math.Max(math.Abs(base), 1)
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// seriesKey identifies a series by its name and labels regardless of map order.
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteString("|")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package metrics_extractor
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	internalModel "github.com/unicoooorn/pingr/internal/model"
+)
+
+// queryCache keeps copies of successful query results for a short TTL.
+// A nil cache or zero TTL disables caching.
+type queryCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	metrics []internalModel.Metric
+	expires time.Time
+}
+
+func newQueryCache(ttl time.Duration) *queryCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &queryCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *queryCache) get(query string) ([]internalModel.Metric, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[query]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, query)
+		return nil, false
+	}
+	return cloneMetrics(entry.metrics), true
+}
+
+func (c *queryCache) put(query string, metrics []internalModel.Metric) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[query] = cacheEntry{
+		metrics: cloneMetrics(metrics),
+		expires: now.Add(c.ttl),
+	}
+}
+
+// cloneMetrics copies metrics with their labels, baseline and series, so that
+// callers can't change a cached result or each other's copies.
+func cloneMetrics(metrics []internalModel.Metric) []internalModel.Metric {
+	if metrics == nil {
+		return nil
+	}
+	out := make([]internalModel.Metric, len(metrics))
+	for i, m := range metrics {
+		m.Labels = maps.Clone(m.Labels)
+		if m.Baseline != nil {
+			baseline := *m.Baseline
+			m.Baseline = &baseline
+		}
+		m.Series = slices.Clone(m.Series)
+		out[i] = m
+	}
+	return out
+}
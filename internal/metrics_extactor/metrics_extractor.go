@@ -3,6 +3,7 @@ package metrics_extractor
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -11,14 +12,23 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/unicoooorn/pingr/internal/config"
 	internalModel "github.com/unicoooorn/pingr/internal/model"
-	"github.com/unicoooorn/pingr/internal/service"
+	"github.com/unicoooorn/pingr/internal/querytemplate"
+	"golang.org/x/sync/errgroup"
 )
 
-// Статическая проверка на то что структура соответствует интерфейсу
-var _ service.MetricsExtractor = &PrometheusMetricsExtractor{}
+const defaultQueryConcurrency = 4
 
+// PrometheusMetricsExtractor runs queries on a single datasource; Router picks
+// it for every query of a backend.
 type PrometheusMetricsExtractor struct {
-	api v1.API
+	datasource   config.PrometheusConfig
+	api          v1.API
+	baseline     config.BaselineConfig
+	sparkline    config.SparklineConfig
+	backends     map[string]config.BackendConfig
+	queryTimeout time.Duration
+	concurrency  int
+	cache        *queryCache
 }
 
 func NewPrometheusMetricsExtractor(cfg config.PrometheusConfig) (*PrometheusMetricsExtractor, error) {
@@ -29,9 +39,9 @@ func NewPrometheusMetricsExtractor(cfg config.PrometheusConfig) (*PrometheusMetr
 	httpClient := &http.Client{
 		Timeout: cfg.Timeout,
 	}
-	if len(cfg.Headers) > 0 {
+	if headers := requestHeaders(cfg); len(headers) > 0 {
 		httpClient.Transport = &headerRoundTripper{
-			headers: cfg.Headers,
+			headers: headers,
 			next:    http.DefaultTransport,
 		}
 	}
@@ -44,11 +54,37 @@ func NewPrometheusMetricsExtractor(cfg config.PrometheusConfig) (*PrometheusMetr
 		return nil, fmt.Errorf("failed to create prometheus client: %w", err)
 	}
 
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultQueryConcurrency
+	}
+
 	return &PrometheusMetricsExtractor{
-		api: v1.NewAPI(client),
+		datasource:   cfg,
+		api:          v1.NewAPI(client),
+		baseline:     cfg.Baseline,
+		sparkline:    cfg.Sparkline,
+		queryTimeout: cfg.QueryTimeout,
+		concurrency:  concurrency,
+		cache:        newQueryCache(cfg.CacheTTL),
 	}, nil
 }
 
+// WithBackends provides backend configs used as template data when rendering queries.
+// Without it only {{.Backend}} is available.
+func (p *PrometheusMetricsExtractor) WithBackends(backends map[string]config.BackendConfig) *PrometheusMetricsExtractor {
+	p.backends = backends
+	return p
+}
+
+// Ping runs a trivial instant query to check that the datasource answers.
+func (p *PrometheusMetricsExtractor) Ping(ctx context.Context) error {
+	if _, _, err := p.api.Query(ctx, "vector(1)", time.Now()); err != nil {
+		return fmt.Errorf("query prometheus: %w", err)
+	}
+	return nil
+}
+
 func (p *PrometheusMetricsExtractor) Extract(ctx context.Context, subsystem string, queries []string) (internalModel.MetricsExtractorResult, error) {
 	if len(queries) == 0 {
 		return internalModel.MetricsExtractorResult{
@@ -57,36 +93,102 @@ func (p *PrometheusMetricsExtractor) Extract(ctx context.Context, subsystem stri
 		}, nil
 	}
 
-	var allMetrics []internalModel.Metric
-	var errors []string
+	vars := querytemplate.NewVars(subsystem, p.backends[subsystem])
+
+	// Запросы выполняются параллельно, но результат собирается в порядке конфига
+	results := make([][]internalModel.Metric, len(queries))
+	failures := make([]*internalModel.QueryFailure, len(queries))
+
+	eg, ectx := errgroup.WithContext(ctx)
+	eg.SetLimit(p.concurrency)
+
+	for i, queryTmpl := range queries {
+		eg.Go(func() error {
+			queryExpr, err := querytemplate.Render(queryTmpl, vars)
+			if err != nil {
+				failures[i] = &internalModel.QueryFailure{
+					Query: queryTmpl,
+					Error: fmt.Sprintf("render query: %v", err),
+				}
+				return nil
+			}
 
-	for _, queryExpr := range queries {
-		metrics, err := p.queryMetrics(ctx, queryExpr)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("query %q: %v", queryExpr, err))
-			continue
+			metrics, err := p.cachedQueryMetrics(ectx, queryExpr)
+			if err != nil {
+				failures[i] = &internalModel.QueryFailure{Query: queryExpr, Error: err.Error()}
+			}
+			results[i] = metrics
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	var allMetrics []internalModel.Metric
+	var allFailures []internalModel.QueryFailure
+	for i := range queries {
+		allMetrics = append(allMetrics, results[i]...)
+		if failures[i] != nil {
+			allFailures = append(allFailures, *failures[i])
 		}
-		allMetrics = append(allMetrics, metrics...)
 	}
 
 	details := fmt.Sprintf("extracted %d metrics", len(allMetrics))
-	if len(errors) > 0 {
-		details = fmt.Sprintf("partial success - %s, errors: %v", details, errors)
+	if len(allFailures) > 0 {
+		details = fmt.Sprintf("partial success - %s, %d of %d queries failed", details, len(allFailures), len(queries))
 	}
 
 	return internalModel.MetricsExtractorResult{
-		Metrics: allMetrics,
-		Details: details,
+		Metrics:  allMetrics,
+		Details:  details,
+		Failures: allFailures,
 	}, nil
 }
 
+// cachedQueryMetrics returns a fresh cached result when available, otherwise
+// queries Prometheus within the per-query timeout.
+func (p *PrometheusMetricsExtractor) cachedQueryMetrics(ctx context.Context, query string) ([]internalModel.Metric, error) {
+	if metrics, ok := p.cache.get(query); ok {
+		return metrics, nil
+	}
+
+	if p.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.queryTimeout)
+		defer cancel()
+	}
+
+	metrics, err := p.queryMetrics(ctx, query)
+	if err != nil {
+		return metrics, err
+	}
+
+	p.cache.put(query, metrics)
+	return metrics, nil
+}
+
 func (p *PrometheusMetricsExtractor) queryMetrics(ctx context.Context, query string) ([]internalModel.Metric, error) {
-	result, _, err := p.api.Query(ctx, query, time.Now())
+	now := time.Now()
+	result, _, err := p.api.Query(ctx, query, now)
 	if err != nil {
 		return nil, err
 	}
 
-	return convertToMetrics(result), nil
+	// Baseline и sparkline только дополняют значения: без них запрос всё равно
+	// успешен и кэшируется, метрики остаются без Baseline или Series
+	metrics := convertToMetrics(result)
+	if p.baseline.Mode != "" {
+		if err := p.annotateBaseline(ctx, query, now, metrics); err != nil {
+			slog.Warn("baseline query failed", "query", query, "error", err)
+		}
+	}
+
+	if p.sparkline.Window > 0 {
+		if err := p.annotateSeries(ctx, query, now, metrics); err != nil {
+			slog.Warn("sparkline query failed", "query", query, "error", err)
+		}
+	}
+
+	return metrics, nil
 }
 
 func convertToMetrics(value model.Value) []internalModel.Metric {
@@ -95,18 +197,7 @@ func convertToMetrics(value model.Value) []internalModel.Metric {
 	switch v := value.(type) {
 	case model.Vector:
 		for _, sample := range v {
-			name := string(sample.Metric["__name__"])
-			if name == "" {
-				name = "unnamed_metric"
-			}
-
-			labels := make(map[string]string)
-			for k, v := range sample.Metric {
-				if k != "__name__" {
-					labels[string(k)] = string(v)
-				}
-			}
-
+			name, labels := splitMetric(sample.Metric)
 			metrics = append(metrics, internalModel.Metric{
 				Name:   name,
 				Value:  float64(sample.Value),
@@ -125,6 +216,34 @@ func convertToMetrics(value model.Value) []internalModel.Metric {
 	return metrics
 }
 
+func splitMetric(metric model.Metric) (string, map[string]string) {
+	name := string(metric["__name__"])
+	if name == "" {
+		name = "unnamed_metric"
+	}
+
+	labels := make(map[string]string)
+	for k, v := range metric {
+		if k != "__name__" {
+			labels[string(k)] = string(v)
+		}
+	}
+	return name, labels
+}
+
+// requestHeaders merges configured headers with the datasource authentication.
+func requestHeaders(cfg config.PrometheusConfig) map[string]string {
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+
+	if auth := cfg.Auth.AuthorizationHeader(); auth != "" {
+		headers["Authorization"] = auth
+	}
+	return headers
+}
+
 type headerRoundTripper struct {
 	headers map[string]string
 	next    http.RoundTripper
@@ -5,16 +5,21 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/unicoooorn/pingr/internal/config"
+	internalModel "github.com/unicoooorn/pingr/internal/model"
 )
 
 func TestPrometheusMetricsExtractor_Extract(t *testing.T) {
 	// Создаём мок Prometheus сервер
+	var mu sync.Mutex
 	var receivedQueries []string
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/v1/query" {
@@ -29,7 +34,9 @@ func TestPrometheusMetricsExtractor_Extract(t *testing.T) {
 		}
 
 		query := r.FormValue("query")
+		mu.Lock()
 		receivedQueries = append(receivedQueries, query)
+		mu.Unlock()
 
 		var response interface{}
 		switch {
@@ -116,9 +123,9 @@ func TestPrometheusMetricsExtractor_Extract(t *testing.T) {
 	require.NoError(t, err)
 
 	// Проверяем что получили правильное количество запросов
+	// Запросы выполняются параллельно, поэтому порядок получения не гарантирован
 	require.Len(t, receivedQueries, 2, "Should have received 2 queries")
-	assert.Equal(t, "up", receivedQueries[0])
-	assert.Equal(t, "http_requests_total", receivedQueries[1])
+	assert.ElementsMatch(t, []string{"up", "http_requests_total"}, receivedQueries)
 
 	// Проверяем результаты
 	assert.Len(t, result.Metrics, 3) // 1 from "up" + 2 from "http_requests_total"
@@ -253,6 +260,9 @@ func TestPrometheusMetricsExtractor_Extract_QueryFailure(t *testing.T) {
 	require.NoError(t, err)
 	assert.Contains(t, result.Details, "partial success")
 	assert.Empty(t, result.Metrics)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, "invalid{", result.Failures[0].Query)
+	assert.Contains(t, result.Failures[0].Error, "invalid query")
 }
 
 func TestPrometheusMetricsExtractor_ConvertToMetrics_Scalar(t *testing.T) {
@@ -401,3 +411,473 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestPrometheusMetricsExtractor_Extract_StdDevBaseline(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		switch r.URL.Path {
+		case "/api/v1/query":
+			response = map[string]interface{}{
+				"status": "success",
+				"data": map[string]interface{}{
+					"resultType": "vector",
+					"result": []map[string]interface{}{
+						{
+							"metric": map[string]string{"__name__": "latency", "service": "api"},
+							"value":  []interface{}{1699999999, "16"},
+						},
+						{
+							"metric": map[string]string{"__name__": "latency", "service": "db"},
+							"value":  []interface{}{1699999999, "11"},
+						},
+						{
+							"metric": map[string]string{"__name__": "latency", "service": "cache"},
+							"value":  []interface{}{1699999999, "10.000000001"},
+						},
+					},
+				},
+			}
+		case "/api/v1/query_range":
+			// среднее 10, σ = 1 для api и db, ровный ряд для cache
+			values := []interface{}{
+				[]interface{}{1699999000, "9"},
+				[]interface{}{1699999060, "11"},
+			}
+			flat := []interface{}{
+				[]interface{}{1699999000, "10"},
+				[]interface{}{1699999060, "10"},
+			}
+			response = map[string]interface{}{
+				"status": "success",
+				"data": map[string]interface{}{
+					"resultType": "matrix",
+					"result": []map[string]interface{}{
+						{"metric": map[string]string{"__name__": "latency", "service": "api"}, "values": values},
+						{"metric": map[string]string{"__name__": "latency", "service": "db"}, "values": values},
+						{"metric": map[string]string{"__name__": "latency", "service": "cache"}, "values": flat},
+					},
+				},
+			}
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{
+		URL: mockServer.URL,
+		Baseline: config.BaselineConfig{
+			Mode:      config.BaselineModeStdDev,
+			Threshold: 3,
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := extractor.Extract(context.Background(), "api", []string{"latency"})
+	require.NoError(t, err)
+	require.Len(t, result.Metrics, 3)
+
+	api, db, cache := result.Metrics[0], result.Metrics[1], result.Metrics[2]
+	require.NotNil(t, api.Baseline)
+	assert.Equal(t, 10.0, api.Baseline.Mean)
+	assert.Equal(t, 1.0, api.Baseline.StdDev)
+	assert.Equal(t, 6.0, api.Baseline.Score)
+	assert.True(t, api.Baseline.Anomalous)
+	assert.Equal(t, "6.0σ above baseline", api.Baseline.String())
+
+	require.NotNil(t, db.Baseline)
+	assert.False(t, db.Baseline.Anomalous)
+
+	// Шум в последнем знаке на ровном ряду не аномалия
+	require.NotNil(t, cache.Baseline)
+	assert.Equal(t, 0.0, cache.Baseline.StdDev)
+	assert.False(t, cache.Baseline.Anomalous)
+}
+
+func TestPrometheusMetricsExtractor_Extract_LastWeekBaseline(t *testing.T) {
+	sample := func(name, value string) map[string]interface{} {
+		return map[string]interface{}{
+			"metric": map[string]string{"__name__": name, "service": "api"},
+			"value":  []interface{}{1699999999, value},
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		at, err := strconv.ParseFloat(r.FormValue("time"), 64)
+		require.NoError(t, err)
+
+		result := []map[string]interface{}{
+			sample("rps", "150"), sample("cpu", "1.1"), sample("errors", "3"), sample("queue", "0.001"),
+		}
+		if time.Unix(int64(at), 0).Before(time.Now().Add(-24 * time.Hour)) {
+			result = []map[string]interface{}{
+				sample("rps", "100"), sample("cpu", "1"), sample("errors", "0"), sample("queue", "0"),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{
+		URL:      mockServer.URL,
+		Baseline: config.BaselineConfig{Mode: config.BaselineModeLastWeek},
+	})
+	require.NoError(t, err)
+
+	result, err := extractor.Extract(context.Background(), "api", []string{"metrics"})
+	require.NoError(t, err)
+	require.Len(t, result.Metrics, 4)
+	rps, cpu, errors, queue := result.Metrics[0], result.Metrics[1], result.Metrics[2], result.Metrics[3]
+
+	require.NotNil(t, rps.Baseline)
+	assert.Equal(t, 100.0, rps.Baseline.Mean)
+	assert.Equal(t, 0.5, rps.Baseline.Score)
+	assert.True(t, rps.Baseline.Anomalous)
+	assert.Equal(t, "+50% vs same time last week", rps.Baseline.String())
+
+	require.NotNil(t, cpu.Baseline)
+	assert.False(t, cpu.Baseline.Anomalous)
+
+	// От нулевого значения процент не считается
+	require.NotNil(t, errors.Baseline)
+	assert.True(t, errors.Baseline.Anomalous)
+	assert.Equal(t, "was 0 a week ago, now 3", errors.Baseline.String())
+
+	require.NotNil(t, queue.Baseline)
+	assert.False(t, queue.Baseline.Anomalous)
+}
+
+// Упавший запрос baseline или sparkline не делает сам запрос неудачным:
+// значение остаётся и кэшируется
+func TestPrometheusMetricsExtractor_Extract_EnrichmentFailureKeepsMetrics(t *testing.T) {
+	var instant atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		instant.Add(1)
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result": []map[string]interface{}{
+					{
+						"metric": map[string]string{"__name__": "up"},
+						"value":  []interface{}{1699999999, "1"},
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{
+		URL:       mockServer.URL,
+		Baseline:  config.BaselineConfig{Mode: config.BaselineModeStdDev},
+		Sparkline: config.SparklineConfig{Window: 30 * time.Minute},
+		CacheTTL:  time.Minute,
+	})
+	require.NoError(t, err)
+
+	for range 2 {
+		result, err := extractor.Extract(context.Background(), "test", []string{"up"})
+		require.NoError(t, err)
+		require.Len(t, result.Metrics, 1)
+		assert.Nil(t, result.Metrics[0].Baseline)
+		assert.Empty(t, result.Metrics[0].Series)
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, "extracted 1 metrics", result.Details)
+	}
+	assert.Equal(t, int32(1), instant.Load())
+}
+
+func TestPrometheusMetricsExtractor_Extract_RendersTemplates(t *testing.T) {
+	var mu sync.Mutex
+	var receivedQueries []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "failed to parse form", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		receivedQueries = append(receivedQueries, r.FormValue("query"))
+		mu.Unlock()
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result":     []map[string]interface{}{},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	backends := map[string]config.BackendConfig{
+		"cache": {
+			Type:   "redis",
+			Host:   "redis-1",
+			Port:   6379,
+			Labels: map[string]string{"team": "core", "env": "prod"},
+		},
+	}
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{URL: mockServer.URL})
+	require.NoError(t, err)
+
+	result, err := extractor.WithBackends(backends).Extract(context.Background(), "cache", []string{
+		`up{job="{{.Backend}}"}`,
+		`redis_up{instance="{{.Host}}:{{.Port}}"}`,
+		`up{ {{.Selector}} }`,
+		`up{team="{{.Labels.team}}"}`,
+		`up{job="{{.Unknown}}"}`,
+	})
+	require.NoError(t, err)
+
+	// Запрос с ошибкой в шаблоне не отправляется в Prometheus
+	assert.ElementsMatch(t, []string{
+		`up{job="cache"}`,
+		`redis_up{instance="redis-1:6379"}`,
+		`up{ env="prod",team="core" }`,
+		`up{team="core"}`,
+	}, receivedQueries)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, `up{job="{{.Unknown}}"}`, result.Failures[0].Query)
+	assert.Contains(t, result.Failures[0].Error, "render query")
+}
+
+func TestPrometheusMetricsExtractor_Extract_QueryTimeout(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "failed to parse form", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("query") == "slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result": []map[string]interface{}{
+					{
+						"metric": map[string]string{"__name__": "fast"},
+						"value":  []interface{}{1699999999, "1"},
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{
+		URL:          mockServer.URL,
+		QueryTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	result, err := extractor.Extract(context.Background(), "test", []string{"slow", "fast"})
+	require.NoError(t, err)
+
+	assert.Less(t, time.Since(start), time.Second)
+	require.Len(t, result.Metrics, 1)
+	assert.Equal(t, "fast", result.Metrics[0].Name)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, "slow", result.Failures[0].Query)
+}
+
+func TestPrometheusMetricsExtractor_Extract_Cache(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result": []map[string]interface{}{
+					{
+						"metric": map[string]string{"__name__": "up"},
+						"value":  []interface{}{1699999999, "1"},
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{
+		URL:      mockServer.URL,
+		CacheTTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	extractor.cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		result, err := extractor.Extract(context.Background(), "test", []string{"up"})
+		require.NoError(t, err)
+		require.Len(t, result.Metrics, 1)
+	}
+	assert.Equal(t, int32(1), requests.Load())
+
+	// После истечения TTL запрос выполняется заново
+	now = now.Add(2 * time.Minute)
+	_, err = extractor.Extract(context.Background(), "test", []string{"up"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+// Кэш отдаёт копии: изменения у одного вызывающего не видны другим
+func TestQueryCache_Copies(t *testing.T) {
+	cache := newQueryCache(time.Minute)
+	metrics := []internalModel.Metric{{
+		Name:     "latency",
+		Value:    1,
+		Labels:   map[string]string{"job": "api"},
+		Baseline: &internalModel.MetricBaseline{Mean: 1},
+		Series:   []float64{1, 2},
+	}}
+	cache.put("latency", metrics)
+
+	metrics[0].Labels["job"] = "changed"
+	metrics[0].Baseline.Mean = 100
+	metrics[0].Series[0] = 100
+
+	got, ok := cache.get("latency")
+	require.True(t, ok)
+	got[0].Labels["job"] = "changed"
+	got[0].Baseline.Anomalous = true
+	got[0].Series[1] = 100
+
+	got, ok = cache.get("latency")
+	require.True(t, ok)
+	assert.Equal(t, []internalModel.Metric{{
+		Name:     "latency",
+		Value:    1,
+		Labels:   map[string]string{"job": "api"},
+		Baseline: &internalModel.MetricBaseline{Mean: 1},
+		Series:   []float64{1, 2},
+	}}, got)
+}
+
+func TestPrometheusMetricsExtractor_Extract_Sparkline(t *testing.T) {
+	var rangeStart, rangeEnd, rangeStep string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		switch r.URL.Path {
+		case "/api/v1/query":
+			response = map[string]interface{}{
+				"status": "success",
+				"data": map[string]interface{}{
+					"resultType": "vector",
+					"result": []map[string]interface{}{
+						{
+							"metric": map[string]string{"__name__": "errors", "service": "api"},
+							"value":  []interface{}{1699999999, "5"},
+						},
+						{
+							"metric": map[string]string{"__name__": "errors", "service": "db"},
+							"value":  []interface{}{1699999999, "0"},
+						},
+					},
+				},
+			}
+		case "/api/v1/query_range":
+			require.NoError(t, r.ParseForm())
+			rangeStart, rangeEnd, rangeStep = r.Form.Get("start"), r.Form.Get("end"), r.Form.Get("step")
+			response = map[string]interface{}{
+				"status": "success",
+				"data": map[string]interface{}{
+					"resultType": "matrix",
+					"result": []map[string]interface{}{
+						{
+							"metric": map[string]string{"__name__": "errors", "service": "api"},
+							"values": []interface{}{
+								[]interface{}{1699999000, "1"},
+								[]interface{}{1699999060, "2"},
+								[]interface{}{1699999120, "5"},
+							},
+						},
+					},
+				},
+			}
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{
+		URL:       mockServer.URL,
+		Sparkline: config.SparklineConfig{Window: 30 * time.Minute},
+	})
+	require.NoError(t, err)
+
+	result, err := extractor.Extract(context.Background(), "api", []string{"errors"})
+	require.NoError(t, err)
+	require.Len(t, result.Metrics, 2)
+
+	assert.Equal(t, []float64{1, 2, 5}, result.Metrics[0].Series)
+	// Ряда для db нет в ответе, sparkline у него пустой
+	assert.Empty(t, result.Metrics[1].Series)
+
+	start, err := strconv.ParseFloat(rangeStart, 64)
+	require.NoError(t, err)
+	end, err := strconv.ParseFloat(rangeEnd, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, (30 * time.Minute).Seconds(), end-start, 1)
+	assert.Equal(t, "60", rangeStep)
+}
+
+func TestPrometheusMetricsExtractor_Ping(t *testing.T) {
+	var query string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.FormValue("query")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result":     []interface{}{},
+			},
+		})
+	}))
+	defer mockServer.Close()
+
+	extractor, err := NewPrometheusMetricsExtractor(config.PrometheusConfig{URL: mockServer.URL})
+	require.NoError(t, err)
+	require.NoError(t, extractor.Ping(context.Background()))
+	assert.Equal(t, "vector(1)", query)
+
+	mockServer.Close()
+	assert.Error(t, extractor.Ping(context.Background()))
+}
@@ -0,0 +1,126 @@
+package metrics_extractor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	internalModel "github.com/unicoooorn/pingr/internal/model"
+	"github.com/unicoooorn/pingr/internal/service"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ service.MetricsExtractor = &Router{}
+
+// Router sends every query of a backend to its datasource, see
+// config.Config.MetricsQueriesFor, and merges the results.
+type Router struct {
+	extractors map[string]*PrometheusMetricsExtractor
+}
+
+func NewRouter(cfg config.Config) (*Router, error) {
+	datasources := cfg.AllDatasources()
+	if len(datasources) == 0 {
+		return nil, fmt.Errorf("no metrics datasources configured")
+	}
+
+	extractors := make(map[string]*PrometheusMetricsExtractor, len(datasources))
+	for name, ds := range datasources {
+		extractor, err := NewPrometheusMetricsExtractor(ds)
+		if err != nil {
+			return nil, fmt.Errorf("datasource %q: %w", name, err)
+		}
+		extractors[name] = extractor.WithBackends(cfg.Backends)
+	}
+
+	return &Router{
+		extractors: extractors,
+	}, nil
+}
+
+// KeepCache reuses the query caches of prev for datasources whose settings
+// didn't change, so a config reload doesn't send every query again.
+func (r *Router) KeepCache(prev *Router) {
+	if prev == nil {
+		return
+	}
+	for name, extractor := range r.extractors {
+		old, ok := prev.extractors[name]
+		if ok && old.cache != nil && extractor.cache != nil && reflect.DeepEqual(old.datasource, extractor.datasource) {
+			extractor.cache = old.cache
+		}
+	}
+}
+
+// Datasource returns the extractor of the named datasource.
+func (r *Router) Datasource(name string) (*PrometheusMetricsExtractor, bool) {
+	extractor, ok := r.extractors[name]
+	return extractor, ok
+}
+
+func (r *Router) Extract(ctx context.Context, backend string, queries []config.MetricsQuery) (internalModel.MetricsExtractorResult, error) {
+	if len(queries) == 0 {
+		return internalModel.MetricsExtractorResult{
+			Metrics: []internalModel.Metric{},
+			Details: "no metrics queries configured",
+		}, nil
+	}
+
+	queriesByDatasource := make(map[string][]string)
+	for _, query := range queries {
+		name := query.Datasource
+		if name == "" {
+			name = config.DefaultDatasource
+		}
+		queriesByDatasource[name] = append(queriesByDatasource[name], query.Query)
+	}
+
+	names := make([]string, 0, len(queriesByDatasource))
+	for name := range queriesByDatasource {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]internalModel.MetricsExtractorResult, len(names))
+
+	eg, ectx := errgroup.WithContext(ctx)
+	for i, name := range names {
+		eg.Go(func() error {
+			extractor, ok := r.extractors[name]
+			if !ok {
+				for _, query := range queriesByDatasource[name] {
+					results[i].Failures = append(results[i].Failures, internalModel.QueryFailure{
+						Query: query,
+						Error: fmt.Sprintf("unknown datasource %q", name),
+					})
+				}
+				return nil
+			}
+
+			res, err := extractor.Extract(ectx, backend, queriesByDatasource[name])
+			if err != nil {
+				return fmt.Errorf("datasource %q: %w", name, err)
+			}
+			results[i] = res
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return internalModel.MetricsExtractorResult{}, err
+	}
+
+	var merged internalModel.MetricsExtractorResult
+	for _, res := range results {
+		merged.Metrics = append(merged.Metrics, res.Metrics...)
+		merged.Failures = append(merged.Failures, res.Failures...)
+	}
+
+	merged.Details = fmt.Sprintf("extracted %d metrics from %d datasources", len(merged.Metrics), len(names))
+	if len(merged.Failures) > 0 {
+		merged.Details = fmt.Sprintf("partial success - %s, %d of %d queries failed", merged.Details, len(merged.Failures), len(queries))
+	}
+
+	return merged, nil
+}
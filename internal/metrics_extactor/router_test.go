@@ -0,0 +1,159 @@
+package metrics_extractor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+	internalModel "github.com/unicoooorn/pingr/internal/model"
+)
+
+// newNamedServer отвечает одной метрикой с именем сервера, чтобы было видно, куда ушёл запрос
+func newNamedServer(t *testing.T, name string, check func(r *http.Request)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		response := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "vector",
+				"result": []map[string]interface{}{
+					{
+						"metric": map[string]string{"__name__": name},
+						"value":  []interface{}{1699999999, "1"},
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRouter_Extract_SelectsDatasource(t *testing.T) {
+	regional := newNamedServer(t, "regional", nil)
+	longterm := newNamedServer(t, "longterm", func(r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "vm", user)
+		assert.Equal(t, "secret", pass)
+	})
+	def := newNamedServer(t, "default", nil)
+
+	cfg := config.Config{
+		Prometheus: config.PrometheusConfig{URL: def.URL},
+		Datasources: map[string]config.PrometheusConfig{
+			"eu": {URL: regional.URL},
+			"vm": {
+				URL:  longterm.URL,
+				Type: "victoriametrics",
+				Auth: config.AuthConfig{Username: "vm", Password: "secret"},
+			},
+		},
+		QuerySets: map[string]config.QuerySetConfig{
+			"history": {Queries: []string{"history_query"}, Datasource: "vm"},
+		},
+		Backends: map[string]config.BackendConfig{
+			"api":   {Datasource: "eu", QuerySets: []string{"history"}, MetricsQueries: []string{"up"}},
+			"plain": {MetricsQueries: []string{"up"}},
+			// Тот же текст запроса в наборе и в metrics_queries идёт в оба источника по разу
+			"dup": {Datasource: "eu", QuerySets: []string{"history"}, MetricsQueries: []string{"history_query"}},
+		},
+	}
+
+	router, err := NewRouter(cfg)
+	require.NoError(t, err)
+
+	result, err := router.Extract(context.Background(), "api", cfg.MetricsQueriesFor("api"))
+	require.NoError(t, err)
+	names := []string{}
+	for _, m := range result.Metrics {
+		names = append(names, m.Name)
+	}
+	assert.ElementsMatch(t, []string{"regional", "longterm"}, names)
+	assert.Empty(t, result.Failures)
+
+	result, err = router.Extract(context.Background(), "plain", cfg.MetricsQueriesFor("plain"))
+	require.NoError(t, err)
+	require.Len(t, result.Metrics, 1)
+	assert.Equal(t, "default", result.Metrics[0].Name)
+
+	result, err = router.Extract(context.Background(), "dup", cfg.MetricsQueriesFor("dup"))
+	require.NoError(t, err)
+	names = []string{}
+	for _, m := range result.Metrics {
+		names = append(names, m.Name)
+	}
+	assert.ElementsMatch(t, []string{"regional", "longterm"}, names)
+}
+
+func TestRouter_Extract_UnknownDatasource(t *testing.T) {
+	def := newNamedServer(t, "default", nil)
+
+	cfg := config.Config{
+		Prometheus: config.PrometheusConfig{URL: def.URL},
+		Backends: map[string]config.BackendConfig{
+			"api": {Datasource: "missing", MetricsQueries: []string{"up"}},
+		},
+	}
+
+	router, err := NewRouter(cfg)
+	require.NoError(t, err)
+
+	result, err := router.Extract(context.Background(), "api", cfg.MetricsQueriesFor("api"))
+	require.NoError(t, err)
+	assert.Empty(t, result.Metrics)
+	require.Len(t, result.Failures, 1)
+	assert.Contains(t, result.Failures[0].Error, `unknown datasource "missing"`)
+}
+
+func TestNewRouter_NoDatasources(t *testing.T) {
+	_, err := NewRouter(config.Config{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no metrics datasources configured")
+}
+
+func TestRouter_KeepCache(t *testing.T) {
+	cfg := config.Config{
+		Prometheus: config.PrometheusConfig{URL: "http://prometheus:9090", CacheTTL: time.Minute},
+		Datasources: map[string]config.PrometheusConfig{
+			"vm": {URL: "http://vm:8428", CacheTTL: time.Minute},
+		},
+	}
+	metrics := []internalModel.Metric{{Name: "up", Value: 1}}
+
+	prev, err := NewRouter(cfg)
+	require.NoError(t, err)
+	for _, name := range []string{config.DefaultDatasource, "vm"} {
+		extractor, _ := prev.Datasource(name)
+		extractor.cache.put("up", metrics)
+	}
+
+	cfg.Datasources = map[string]config.PrometheusConfig{
+		"vm": {URL: "http://vm-2:8428", CacheTTL: time.Minute},
+	}
+	next, err := NewRouter(cfg)
+	require.NoError(t, err)
+	next.KeepCache(prev)
+
+	unchanged, _ := next.Datasource(config.DefaultDatasource)
+	cached, ok := unchanged.cache.get("up")
+	assert.True(t, ok)
+	assert.Equal(t, metrics, cached)
+
+	// Другой адрес — другие данные, кэш не переносится
+	moved, _ := next.Datasource("vm")
+	_, ok = moved.cache.get("up")
+	assert.False(t, ok)
+
+	next.KeepCache(nil)
+}
@@ -0,0 +1,61 @@
+package metrics_extractor
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	internalModel "github.com/unicoooorn/pingr/internal/model"
+)
+
+const (
+	// Столько точек получается при шаге по умолчанию
+	defaultSparklinePoints = 30
+	minSparklineStep       = time.Second
+)
+
+// annotateSeries fills Metric.Series with the values of the matching series
+// over the sparkline window, ending at the instant query time.
+func (p *PrometheusMetricsExtractor) annotateSeries(ctx context.Context, query string, now time.Time, metrics []internalModel.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	step := p.sparkline.Step
+	if step <= 0 {
+		step = max(p.sparkline.Window/defaultSparklinePoints, minSparklineStep)
+	}
+
+	value, _, err := p.api.QueryRange(ctx, query, v1.Range{
+		Start: now.Add(-p.sparkline.Window),
+		End:   now,
+		Step:  step,
+	})
+	if err != nil {
+		return err
+	}
+
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return nil
+	}
+
+	series := make(map[string][]float64, len(matrix))
+	for _, stream := range matrix {
+		name, labels := splitMetric(stream.Metric)
+		values := make([]float64, 0, len(stream.Values))
+		for _, pair := range stream.Values {
+			values = append(values, float64(pair.Value))
+		}
+		series[seriesKey(name, labels)] = values
+	}
+
+	for i := range metrics {
+		if values, ok := series[seriesKey(metrics[i].Name, metrics[i].Labels)]; ok {
+			metrics[i].Series = values
+		}
+	}
+
+	return nil
+}
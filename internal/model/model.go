@@ -1,5 +1,13 @@
 package model
 
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
 type PingStatus string
 
 const (
@@ -8,9 +16,45 @@ const (
 )
 
 type Metric struct {
-	Name   string
-	Value  float64
-	Labels map[string]string
+	Name     string
+	Value    float64
+	Labels   map[string]string
+	Baseline *MetricBaseline
+	// Значения за окно sparkline, от старых к новым. Пустой, если окно не настроено
+	Series []float64
+}
+
+// Сравнение текущего значения метрики с её обычным поведением
+type MetricBaseline struct {
+	Mode      string  // config.BaselineModeStdDev или config.BaselineModeLastWeek
+	Mean      float64 // среднее за окно или значение неделю назад
+	StdDev    float64
+	Delta     float64 // Value - Mean
+	Score     float64 // отклонение в σ для stddev, относительное изменение для last_week
+	Anomalous bool
+}
+
+func (b *MetricBaseline) String() string {
+	if b == nil {
+		return ""
+	}
+	direction := "above"
+	if b.Delta < 0 {
+		direction = "below"
+	}
+	switch b.Mode {
+	case config.BaselineModeLastWeek:
+		// От нуля процент не посчитать
+		if b.Mean == 0 {
+			return fmt.Sprintf("was 0 a week ago, now %g", b.Delta)
+		}
+		return fmt.Sprintf("%+.0f%% vs same time last week", b.Score*100)
+	default:
+		if b.StdDev == 0 {
+			return fmt.Sprintf("%g %s flat baseline %g", math.Abs(b.Delta), direction, b.Mean)
+		}
+		return fmt.Sprintf("%.1fσ %s baseline", math.Abs(b.Score), direction)
+	}
 }
 
 // Результат работы Checker
@@ -21,11 +65,75 @@ type CheckResult struct {
 
 // Результат работы  MetricsExtractor
 type MetricsExtractorResult struct {
-	Metrics []Metric
+	Metrics  []Metric
+	Details  string
+	Failures []QueryFailure
+}
+
+// Запрос метрик, который не удалось выполнить
+type QueryFailure struct {
+	Query string
+	Error string
+}
+
+// Строка лога упавшего бэкенда
+type LogLine struct {
+	Timestamp time.Time
+	Line      string
+	Labels    map[string]string
+}
+
+// Результат работы LogExtractor, строки от новых к старым
+type LogExtractorResult struct {
+	Lines   []LogLine
 	Details string
 }
 
 type SubsystemInfo struct {
 	Check  CheckResult
 	Metric MetricsExtractorResult
+	Logs   LogExtractorResult
+	// Бэкенд назван вероятной первопричиной инцидента в RCA
+	SuspectedRootCause bool
+	// Последние смены статуса бэкенда, от старых к новым
+	Timeline []StatusChange
+}
+
+// Смена статуса бэкенда между проверками. From пустой для первой проверки
+type StatusChange struct {
+	At      time.Time
+	From    PingStatus
+	To      PingStatus
+	Details string
+	// RCA алерта, отправленного, пока бэкенд был в этом статусе
+	Analysis *RCAAnalysis
+}
+
+// Формат, в котором InfographicsRenderer отдаёт инфографику
+type InfographicFormat string
+
+const (
+	InfographicFormatPNG  InfographicFormat = "png"
+	InfographicFormatSVG  InfographicFormat = "svg"
+	InfographicFormatHTML InfographicFormat = "html"
+)
+
+// Отрисованная инфографика и её MIME-тип
+type Infographic struct {
+	Data        []byte
+	ContentType string
+}
+
+// Структурированный результат RCA от AlertGenerator
+type RCAAnalysis struct {
+	RootCauseBackend string   `json:"root_cause_backend"`
+	Confidence       float64  `json:"confidence"`
+	Summary          string   `json:"summary"`
+	RemediationSteps []string `json:"remediation_steps"`
+}
+
+// Результат работы AlertGenerator. Analysis пустой, если генератор не делает RCA
+type AlertMessage struct {
+	Text     string
+	Analysis *RCAAnalysis
 }
@@ -0,0 +1,66 @@
+// Package querytemplate renders metrics and log queries written as Go
+// templates with the variables of a backend, e.g. `up{job="{{.Backend}}"}`.
+package querytemplate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
+// Vars is the data available to query templates.
+type Vars struct {
+	Backend string
+	Type    string
+	Host    string
+	Port    int
+	URL     string
+	Labels  map[string]string
+	// Selector is Labels formatted as PromQL and LogQL matchers: `env="prod",team="core"`.
+	Selector string
+}
+
+func NewVars(backend string, cfg config.BackendConfig) Vars {
+	keys := make([]string, 0, len(cfg.Labels))
+	for k := range cfg.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	matchers := make([]string, 0, len(keys))
+	for _, k := range keys {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", k, cfg.Labels[k]))
+	}
+
+	return Vars{
+		Backend:  backend,
+		Type:     cfg.Type,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		URL:      cfg.URL,
+		Labels:   cfg.Labels,
+		Selector: strings.Join(matchers, ","),
+	}
+}
+
+// Render executes query as a Go template. Queries without template
+// actions are returned unchanged.
+func Render(query string, vars Vars) (string, error) {
+	if !strings.Contains(query, "{{") {
+		return query, nil
+	}
+
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return b.String(), nil
+}
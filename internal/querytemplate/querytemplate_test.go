@@ -0,0 +1,27 @@
+package querytemplate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
+func TestRender(t *testing.T) {
+	vars := NewVars("api", config.BackendConfig{
+		Type:   "http",
+		Labels: map[string]string{"team": "core", "env": "prod"},
+	})
+
+	got, err := Render(`rate(errors{job="{{.Backend}}",{{.Selector}}}[5m])`, vars)
+	require.NoError(t, err)
+	assert.Equal(t, `rate(errors{job="api",env="prod",team="core"}[5m])`, got)
+
+	got, err = Render(`{job="api"} |= "error"`, vars)
+	require.NoError(t, err)
+	assert.Equal(t, `{job="api"} |= "error"`, got)
+
+	_, err = Render(`up{job="{{.Missing}}"}`, vars)
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+// Package redact scrubs credentials from text before it leaves the process,
+// e.g. in an LLM prompt or an alert message.
+package redact
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
+const mask = "***"
+
+// Секреты короче этого не вырезаются буквально, чтобы не портить обычный текст
+const minSecretLen = 4
+
+// Заголовки, которые несут учётные данные; остальные, например Content-Type, не секрет
+var credentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+}
+
+var credentialHeaderWords = []string{"token", "key", "secret"}
+
+type rule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+var defaultRules = []rule{
+	// user:password@ в URL и DSN
+	{re: regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/\s:@]+:[^/\s@]+@`), repl: "${1}" + mask + "@"},
+	// Authorization: Bearer/Basic <token>
+	{re: regexp.MustCompile(`(?i)\b((?:proxy-)?authorization\s*[=:]\s*(?:bearer|basic)\s+)[A-Za-z0-9\-._~+/]+=*`), repl: "${1}" + mask},
+	// Bearer/Basic без заголовка: только значение, похожее на токен, а не «basic connectivity»
+	{re: regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]{20,}=*`), repl: "${1} " + mask},
+	// password=..., token=..., api_key=... в DSN, URL и логах
+	{re: regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key)=[^\s,;&"']+`), repl: "${1}=" + mask},
+	// password: ..., token: "..." — значение в кавычках или с цифрами, чтобы не задеть прозу вроде «token: expired»
+	{re: regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key)(:\s*)(?:"[^"]*"|'[^']*'|[^\s,;&"']*[0-9][^\s,;&"']*)`), repl: "${1}${2}" + mask},
+}
+
+type Redactor struct {
+	secrets []string
+	rules   []rule
+}
+
+// New builds a redactor that masks secret values found in cfg, the built-in
+// patterns unless disabled, and custom regexes from the redaction section.
+func New(cfg config.Config) (*Redactor, error) {
+	r := &Redactor{secrets: collectSecrets(cfg)}
+
+	if !cfg.Redaction.DisableDefaults {
+		r.rules = append(r.rules, defaultRules...)
+	}
+	for _, pattern := range cfg.Redaction.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile redaction pattern %q: %w", pattern, err)
+		}
+		r.rules = append(r.rules, rule{re: re, repl: mask})
+	}
+	return r, nil
+}
+
+// Redact returns s with all known secrets and pattern matches masked.
+// A nil Redactor returns s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, mask)
+	}
+	for _, rule := range r.rules {
+		s = rule.re.ReplaceAllString(s, rule.repl)
+	}
+	return s
+}
+
+// collectSecrets gathers credential values from the config, longest first so
+// that a secret containing another one is masked as a whole.
+func collectSecrets(cfg config.Config) []string {
+	set := make(map[string]struct{})
+	add := func(values ...string) {
+		for _, v := range values {
+			if len(v) >= minSecretLen {
+				set[v] = struct{}{}
+			}
+		}
+	}
+	addHeaders := func(headers map[string]string) {
+		for name, v := range headers {
+			if !isCredentialHeader(name) {
+				continue
+			}
+			add(v)
+			// "Basic <creds>": сами учётные данные встречаются в тексте и без схемы
+			if _, creds, ok := strings.Cut(v, " "); ok {
+				add(strings.TrimSpace(creds))
+			}
+		}
+	}
+	addAuth := func(auth config.AuthConfig) {
+		add(auth.Password, auth.BearerToken)
+	}
+	addURL := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil || u.User == nil {
+			return
+		}
+		if password, ok := u.User.Password(); ok {
+			add(password)
+		}
+	}
+
+	for _, bc := range cfg.Backends {
+		addHeaders(bc.Headers)
+		addURL(bc.URL)
+	}
+	for _, ds := range cfg.AllDatasources() {
+		addHeaders(ds.Headers)
+		addAuth(ds.Auth)
+		addURL(ds.URL)
+	}
+	addHeaders(cfg.Loki.Headers)
+	addAuth(cfg.Loki.Auth)
+	addURL(cfg.Loki.URL)
+	addHeaders(cfg.LLM.Headers)
+	add(cfg.LLM.APIKey)
+	add(cfg.Telegram.Token)
+
+	secrets := make([]string, 0, len(set))
+	for v := range set {
+		secrets = append(secrets, v)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+	return secrets
+}
+
+func isCredentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	if credentialHeaders[name] {
+		return true
+	}
+	for _, word := range credentialHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
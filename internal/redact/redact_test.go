@@ -0,0 +1,109 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/unicoooorn/pingr/internal/config"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {Headers: map[string]string{"X-Api-Key": "k3y-from-headers"}},
+			"db":  {URL: "postgres://app:s3cr3t-pw@db:5432/app"},
+		},
+		Prometheus: config.PrometheusConfig{
+			URL:  "http://prometheus:9090",
+			Auth: config.AuthConfig{BearerToken: "prom-token"},
+		},
+		Telegram: config.TelegramConfig{Token: "123456:tg-bot-token"},
+		Redaction: config.RedactionConfig{
+			Patterns: []string{`card-\d{4}`},
+		},
+	}
+
+	r, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"dial postgres://app:s3cr3t-pw@db:5432/app failed", "dial postgres://***@db:5432/app failed"},
+		{"pq: password s3cr3t-pw rejected", "pq: password *** rejected"},
+		{"header k3y-from-headers invalid", "header *** invalid"},
+		{"Authorization: Bearer abc.def-ghi", "Authorization: Bearer ***"},
+		{"using prom-token", "using ***"},
+		{"POST /bot123456:tg-bot-token/sendMessage", "POST /bot***/sendMessage"},
+		{"host=db password=hunter2 sslmode=disable", "host=db password=*** sslmode=disable"},
+		{"charged card-1234", "charged ***"},
+		{"http status code: 502", "http status code: 502"},
+		{"proxy-authorization: basic dXNlcjpwYXNz", "proxy-authorization: basic ***"},
+		{"curl -H 'Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln'", "curl -H 'Bearer ***'"},
+		{"GET /export?token=abc&format=csv", "GET /export?token=***&format=csv"},
+		{"api_key: sk-abc123", "api_key: ***"},
+		{`secret: "plain words"`, "secret: ***"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.Redact(tt.input), "input: %q", tt.input)
+	}
+}
+
+func TestRedactor_OrdinaryHeaders(t *testing.T) {
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"api": {Headers: map[string]string{
+				"Accept":        "application/json",
+				"X-Feature":     "latest",
+				"Authorization": "Basic dXNlcjpwYXNz",
+				"Cookie":        "session=c00kie-value",
+				"X-Auth-Token":  "t0ken-value",
+			}},
+		},
+		LLM: config.LLMConfig{Headers: map[string]string{"X-Client-Secret": "llm-s3cret"}},
+	}
+
+	r, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "latest build returns application/json", r.Redact("latest build returns application/json"))
+	assert.Equal(t, "sent *** and *** and ***", r.Redact("sent session=c00kie-value and t0ken-value and llm-s3cret"))
+	assert.Equal(t, "got ***", r.Redact("got dXNlcjpwYXNz"))
+}
+
+// Обычный текст RCA от LLM и из шаблона проходит без изменений
+func TestRedactor_Prose(t *testing.T) {
+	r, err := New(config.Config{})
+	require.NoError(t, err)
+
+	for _, text := range []string{
+		"Run basic connectivity checks against the database",
+		"Bearer authentication fails for the api backend",
+		"Check the Basic auth settings of the gateway",
+		"Rotate the token: the current one has expired",
+		"The secret : values are mounted from the vault",
+		"Root cause: token = missing in the request, password reset required",
+		"The api key was not rotated in time",
+	} {
+		assert.Equal(t, text, r.Redact(text))
+	}
+}
+
+func TestRedactor_DisableDefaults(t *testing.T) {
+	r, err := New(config.Config{Redaction: config.RedactionConfig{DisableDefaults: true}})
+	require.NoError(t, err)
+	assert.Equal(t, "password=hunter2", r.Redact("password=hunter2"))
+}
+
+func TestRedactor_InvalidPattern(t *testing.T) {
+	_, err := New(config.Config{Redaction: config.RedactionConfig{Patterns: []string{"("}}})
+	assert.Error(t, err)
+}
+
+func TestRedactor_Nil(t *testing.T) {
+	var r *Redactor
+	assert.Equal(t, "password=hunter2", r.Redact("password=hunter2"))
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 
+	"github.com/unicoooorn/pingr/internal/config"
 	"github.com/unicoooorn/pingr/internal/model"
 )
 
@@ -11,14 +12,18 @@ type Checker interface {
 }
 
 type MetricsExtractor interface {
-	Extract(ctx context.Context, backend string, queries []string) (model.MetricsExtractorResult, error)
+	Extract(ctx context.Context, backend string, queries []config.MetricsQuery) (model.MetricsExtractorResult, error)
+}
+
+type LogExtractor interface {
+	Extract(ctx context.Context, backend string, query string) (model.LogExtractorResult, error)
 }
 
 type AlertGenerator interface {
 	GenerateAlertMessage(
 		ctx context.Context,
 		subsystemInfoByName map[string]model.SubsystemInfo,
-	) (string, error)
+	) (model.AlertMessage, error)
 }
 
 type AlertSender interface {
@@ -31,6 +36,12 @@ type AlertSender interface {
 	Poll(ctx context.Context) (starts []string, stops []string, err error)
 }
 
+// InfographicsRenderer draws the dependency graph in the format the consumer
+// needs: PNG for chat messages, SVG or HTML for pages and email.
 type InfographicsRenderer interface {
-	Render(ctx context.Context, infos map[string]model.SubsystemInfo) ([]byte, error)
+	Render(
+		ctx context.Context,
+		infos map[string]model.SubsystemInfo,
+		format model.InfographicFormat,
+	) (model.Infographic, error)
 }
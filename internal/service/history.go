@@ -0,0 +1,114 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+const defaultHistorySize = 20
+
+// checkHistory хранит ограниченное число последних смен статуса каждого бэкенда,
+// чтобы RCA видел, что упало первым.
+type checkHistory struct {
+	mu      sync.Mutex
+	size    int
+	now     func() time.Time
+	last    map[string]model.PingStatus
+	changes map[string][]model.StatusChange
+}
+
+func newCheckHistory(size int) *checkHistory {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &checkHistory{
+		size:    size,
+		now:     time.Now,
+		last:    make(map[string]model.PingStatus),
+		changes: make(map[string][]model.StatusChange),
+	}
+}
+
+// record запоминает результат проверки, если статус бэкенда изменился.
+func (h *checkHistory) record(backend string, res model.CheckResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prev, seen := h.last[backend]
+	if seen && prev == res.Status {
+		return
+	}
+	h.last[backend] = res.Status
+
+	changes := append(h.changes[backend], model.StatusChange{
+		At:      h.now(),
+		From:    prev,
+		To:      res.Status,
+		Details: res.Details,
+	})
+	if len(changes) > h.size {
+		changes = append([]model.StatusChange(nil), changes[len(changes)-h.size:]...)
+	}
+	h.changes[backend] = changes
+}
+
+// timeline возвращает копию смен статуса бэкенда, от старых к новым.
+func (h *checkHistory) timeline(backend string) []model.StatusChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]model.StatusChange(nil), h.changes[backend]...)
+}
+
+// annotate сохраняет RCA в последней смене статуса упавших бэкендов, чтобы
+// история инцидента хранила, какую первопричину назвал алерт.
+func (h *checkHistory) annotate(backends []string, analysis *model.RCAAnalysis) {
+	if analysis == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, backend := range backends {
+		changes := h.changes[backend]
+		if len(changes) == 0 || changes[len(changes)-1].To != model.PingStatusNotOk {
+			continue
+		}
+		changes[len(changes)-1].Analysis = analysis
+	}
+}
+
+// resize меняет число хранимых смен статуса, лишние старые смены отбрасываются.
+func (h *checkHistory) resize(size int) {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.size = size
+	for backend, changes := range h.changes {
+		if len(changes) > size {
+			h.changes[backend] = append([]model.StatusChange(nil), changes[len(changes)-size:]...)
+		}
+	}
+}
+
+// retain забывает бэкенды, удалённые из конфига, чтобы вернувшийся бэкенд
+// начинал историю заново.
+func (h *checkHistory) retain(backends map[string]config.BackendConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for backend := range h.last {
+		if _, ok := backends[backend]; !ok {
+			delete(h.last, backend)
+			delete(h.changes, backend)
+		}
+	}
+}
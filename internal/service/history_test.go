@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/model"
+)
+
+func TestCheckHistory_RecordsTransitionsOnly(t *testing.T) {
+	h := newCheckHistory(2)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	h.now = func() time.Time { return now }
+
+	h.record("api", model.CheckResult{Status: model.PingStatusOk})
+	now = now.Add(10 * time.Second)
+	h.record("api", model.CheckResult{Status: model.PingStatusOk})
+	now = now.Add(10 * time.Second)
+	h.record("api", model.CheckResult{Status: model.PingStatusNotOk, Details: "502"})
+
+	assert.Equal(t, []model.StatusChange{
+		{At: start, From: "", To: model.PingStatusOk},
+		{At: start.Add(20 * time.Second), From: model.PingStatusOk, To: model.PingStatusNotOk, Details: "502"},
+	}, h.timeline("api"))
+
+	// RCA алерта сохраняется в смене статуса упавшего бэкенда
+	analysis := &model.RCAAnalysis{RootCauseBackend: "api", Confidence: 0.8, Summary: "api returns 502"}
+	h.record("db", model.CheckResult{Status: model.PingStatusOk})
+	h.annotate([]string{"api", "db", "cache"}, analysis)
+	h.annotate([]string{"api"}, nil)
+	assert.Equal(t, analysis, h.timeline("api")[1].Analysis)
+	assert.Nil(t, h.timeline("api")[0].Analysis)
+	assert.Nil(t, h.timeline("db")[0].Analysis)
+	assert.Empty(t, h.timeline("cache"))
+
+	// Старые переходы вытесняются
+	now = now.Add(10 * time.Second)
+	h.record("api", model.CheckResult{Status: model.PingStatusOk})
+
+	timeline := h.timeline("api")
+	assert.Len(t, timeline, 2)
+	assert.Equal(t, model.PingStatusNotOk, timeline[0].To)
+	assert.Equal(t, model.PingStatusOk, timeline[1].To)
+	assert.Equal(t, analysis, timeline[0].Analysis)
+}
+
+func TestCheckHistory_Resize(t *testing.T) {
+	h := newCheckHistory(3)
+	for _, status := range []model.PingStatus{model.PingStatusOk, model.PingStatusNotOk, model.PingStatusOk} {
+		h.record("api", model.CheckResult{Status: status})
+	}
+
+	h.resize(1)
+	timeline := h.timeline("api")
+	assert.Len(t, timeline, 1)
+	assert.Equal(t, model.PingStatusNotOk, timeline[0].From)
+
+	h.resize(0)
+	for i := 0; i < defaultHistorySize+5; i++ {
+		h.record("api", model.CheckResult{Status: []model.PingStatus{model.PingStatusNotOk, model.PingStatusOk}[i%2]})
+	}
+	assert.Len(t, h.timeline("api"), defaultHistorySize)
+}
+
+func TestCheckHistory_RetainDropsRemovedBackends(t *testing.T) {
+	h := newCheckHistory(0)
+	h.record("api", model.CheckResult{Status: model.PingStatusOk})
+	h.record("legacy", model.CheckResult{Status: model.PingStatusOk})
+
+	h.retain(map[string]config.BackendConfig{"api": {}})
+
+	assert.Len(t, h.timeline("api"), 1)
+	assert.Empty(t, h.timeline("legacy"))
+
+	// Вернувшийся бэкенд начинает историю заново
+	h.record("legacy", model.CheckResult{Status: model.PingStatusOk})
+	assert.Equal(t, model.PingStatus(""), h.timeline("legacy")[0].From)
+}
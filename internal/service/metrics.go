@@ -0,0 +1,20 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var renderFailures = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "pingr",
+	Subsystem: "infographics",
+	Name:      "render_failures_total",
+	Help:      "Infographics that failed to render; the alert was sent as text only.",
+})
+
+var alertsSkipped = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "pingr",
+	Subsystem: "alerts",
+	Name:      "skipped_total",
+	Help:      "Alerts not started because the alert of a previous check was still in progress.",
+})
@@ -39,22 +39,22 @@ func (_m *MockAlertGenerator) EXPECT() *MockAlertGenerator_Expecter {
 }
 
 // GenerateAlertMessage provides a mock function for the type MockAlertGenerator
-func (_mock *MockAlertGenerator) GenerateAlertMessage(ctx context.Context, subsystemInfoByName map[string]model.SubsystemInfo) (string, error) {
+func (_mock *MockAlertGenerator) GenerateAlertMessage(ctx context.Context, subsystemInfoByName map[string]model.SubsystemInfo) (model.AlertMessage, error) {
 	ret := _mock.Called(ctx, subsystemInfoByName)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GenerateAlertMessage")
 	}
 
-	var r0 string
+	var r0 model.AlertMessage
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo) (string, error)); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo) (model.AlertMessage, error)); ok {
 		return returnFunc(ctx, subsystemInfoByName)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo) string); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo) model.AlertMessage); ok {
 		r0 = returnFunc(ctx, subsystemInfoByName)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(model.AlertMessage)
 	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, map[string]model.SubsystemInfo) error); ok {
 		r1 = returnFunc(ctx, subsystemInfoByName)
@@ -94,12 +94,12 @@ func (_c *MockAlertGenerator_GenerateAlertMessage_Call) Run(run func(ctx context
 	return _c
 }
 
-func (_c *MockAlertGenerator_GenerateAlertMessage_Call) Return(s string, err error) *MockAlertGenerator_GenerateAlertMessage_Call {
-	_c.Call.Return(s, err)
+func (_c *MockAlertGenerator_GenerateAlertMessage_Call) Return(alertMessage model.AlertMessage, err error) *MockAlertGenerator_GenerateAlertMessage_Call {
+	_c.Call.Return(alertMessage, err)
 	return _c
 }
 
-func (_c *MockAlertGenerator_GenerateAlertMessage_Call) RunAndReturn(run func(ctx context.Context, subsystemInfoByName map[string]model.SubsystemInfo) (string, error)) *MockAlertGenerator_GenerateAlertMessage_Call {
+func (_c *MockAlertGenerator_GenerateAlertMessage_Call) RunAndReturn(run func(ctx context.Context, subsystemInfoByName map[string]model.SubsystemInfo) (model.AlertMessage, error)) *MockAlertGenerator_GenerateAlertMessage_Call {
 	_c.Call.Return(run)
 	return _c
 }
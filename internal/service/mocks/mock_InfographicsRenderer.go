@@ -39,27 +39,25 @@ func (_m *MockInfographicsRenderer) EXPECT() *MockInfographicsRenderer_Expecter
 }
 
 // Render provides a mock function for the type MockInfographicsRenderer
-func (_mock *MockInfographicsRenderer) Render(ctx context.Context, infos map[string]model.SubsystemInfo) ([]byte, error) {
-	ret := _mock.Called(ctx, infos)
+func (_mock *MockInfographicsRenderer) Render(ctx context.Context, infos map[string]model.SubsystemInfo, format model.InfographicFormat) (model.Infographic, error) {
+	ret := _mock.Called(ctx, infos, format)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Render")
 	}
 
-	var r0 []byte
+	var r0 model.Infographic
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo) ([]byte, error)); ok {
-		return returnFunc(ctx, infos)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo, model.InfographicFormat) (model.Infographic, error)); ok {
+		return returnFunc(ctx, infos, format)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo) []byte); ok {
-		r0 = returnFunc(ctx, infos)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, map[string]model.SubsystemInfo, model.InfographicFormat) model.Infographic); ok {
+		r0 = returnFunc(ctx, infos, format)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
+		r0 = ret.Get(0).(model.Infographic)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, map[string]model.SubsystemInfo) error); ok {
-		r1 = returnFunc(ctx, infos)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, map[string]model.SubsystemInfo, model.InfographicFormat) error); ok {
+		r1 = returnFunc(ctx, infos, format)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -74,11 +72,12 @@ type MockInfographicsRenderer_Render_Call struct {
 // Render is a helper method to define mock.On call
 //   - ctx context.Context
 //   - infos map[string]model.SubsystemInfo
-func (_e *MockInfographicsRenderer_Expecter) Render(ctx interface{}, infos interface{}) *MockInfographicsRenderer_Render_Call {
-	return &MockInfographicsRenderer_Render_Call{Call: _e.mock.On("Render", ctx, infos)}
+//   - format model.InfographicFormat
+func (_e *MockInfographicsRenderer_Expecter) Render(ctx interface{}, infos interface{}, format interface{}) *MockInfographicsRenderer_Render_Call {
+	return &MockInfographicsRenderer_Render_Call{Call: _e.mock.On("Render", ctx, infos, format)}
 }
 
-func (_c *MockInfographicsRenderer_Render_Call) Run(run func(ctx context.Context, infos map[string]model.SubsystemInfo)) *MockInfographicsRenderer_Render_Call {
+func (_c *MockInfographicsRenderer_Render_Call) Run(run func(ctx context.Context, infos map[string]model.SubsystemInfo, format model.InfographicFormat)) *MockInfographicsRenderer_Render_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -88,20 +87,25 @@ func (_c *MockInfographicsRenderer_Render_Call) Run(run func(ctx context.Context
 		if args[1] != nil {
 			arg1 = args[1].(map[string]model.SubsystemInfo)
 		}
+		var arg2 model.InfographicFormat
+		if args[2] != nil {
+			arg2 = args[2].(model.InfographicFormat)
+		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *MockInfographicsRenderer_Render_Call) Return(bytes []byte, err error) *MockInfographicsRenderer_Render_Call {
-	_c.Call.Return(bytes, err)
+func (_c *MockInfographicsRenderer_Render_Call) Return(infographic model.Infographic, err error) *MockInfographicsRenderer_Render_Call {
+	_c.Call.Return(infographic, err)
 	return _c
 }
 
-func (_c *MockInfographicsRenderer_Render_Call) RunAndReturn(run func(ctx context.Context, infos map[string]model.SubsystemInfo) ([]byte, error)) *MockInfographicsRenderer_Render_Call {
+func (_c *MockInfographicsRenderer_Render_Call) RunAndReturn(run func(ctx context.Context, infos map[string]model.SubsystemInfo, format model.InfographicFormat) (model.Infographic, error)) *MockInfographicsRenderer_Render_Call {
 	_c.Call.Return(run)
 	return _c
 }
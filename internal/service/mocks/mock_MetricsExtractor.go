@@ -8,6 +8,7 @@ import (
 	"context"
 
 	mock "github.com/stretchr/testify/mock"
+	"github.com/unicoooorn/pingr/internal/config"
 	"github.com/unicoooorn/pingr/internal/model"
 )
 
@@ -39,7 +40,7 @@ func (_m *MockMetricsExtractor) EXPECT() *MockMetricsExtractor_Expecter {
 }
 
 // Extract provides a mock function for the type MockMetricsExtractor
-func (_mock *MockMetricsExtractor) Extract(ctx context.Context, backend string, queries []string) (model.MetricsExtractorResult, error) {
+func (_mock *MockMetricsExtractor) Extract(ctx context.Context, backend string, queries []config.MetricsQuery) (model.MetricsExtractorResult, error) {
 	ret := _mock.Called(ctx, backend, queries)
 
 	if len(ret) == 0 {
@@ -48,15 +49,15 @@ func (_mock *MockMetricsExtractor) Extract(ctx context.Context, backend string,
 
 	var r0 model.MetricsExtractorResult
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []string) (model.MetricsExtractorResult, error)); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []config.MetricsQuery) (model.MetricsExtractorResult, error)); ok {
 		return returnFunc(ctx, backend, queries)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []string) model.MetricsExtractorResult); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, []config.MetricsQuery) model.MetricsExtractorResult); ok {
 		r0 = returnFunc(ctx, backend, queries)
 	} else {
 		r0 = ret.Get(0).(model.MetricsExtractorResult)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, []config.MetricsQuery) error); ok {
 		r1 = returnFunc(ctx, backend, queries)
 	} else {
 		r1 = ret.Error(1)
@@ -72,12 +73,12 @@ type MockMetricsExtractor_Extract_Call struct {
 // Extract is a helper method to define mock.On call
 //   - ctx context.Context
 //   - backend string
-//   - queries []string
+//   - queries []config.MetricsQuery
 func (_e *MockMetricsExtractor_Expecter) Extract(ctx interface{}, backend interface{}, queries interface{}) *MockMetricsExtractor_Extract_Call {
 	return &MockMetricsExtractor_Extract_Call{Call: _e.mock.On("Extract", ctx, backend, queries)}
 }
 
-func (_c *MockMetricsExtractor_Extract_Call) Run(run func(ctx context.Context, backend string, queries []string)) *MockMetricsExtractor_Extract_Call {
+func (_c *MockMetricsExtractor_Extract_Call) Run(run func(ctx context.Context, backend string, queries []config.MetricsQuery)) *MockMetricsExtractor_Extract_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
@@ -87,9 +88,9 @@ func (_c *MockMetricsExtractor_Extract_Call) Run(run func(ctx context.Context, b
 		if args[1] != nil {
 			arg1 = args[1].(string)
 		}
-		var arg2 []string
+		var arg2 []config.MetricsQuery
 		if args[2] != nil {
-			arg2 = args[2].([]string)
+			arg2 = args[2].([]config.MetricsQuery)
 		}
 		run(
 			arg0,
@@ -105,7 +106,7 @@ func (_c *MockMetricsExtractor_Extract_Call) Return(metricsExtractorResult model
 	return _c
 }
 
-func (_c *MockMetricsExtractor_Extract_Call) RunAndReturn(run func(ctx context.Context, backend string, queries []string) (model.MetricsExtractorResult, error)) *MockMetricsExtractor_Extract_Call {
+func (_c *MockMetricsExtractor_Extract_Call) RunAndReturn(run func(ctx context.Context, backend string, queries []config.MetricsQuery) (model.MetricsExtractorResult, error)) *MockMetricsExtractor_Extract_Call {
 	_c.Call.Return(run)
 	return _c
 }
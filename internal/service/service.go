@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 
 	"github.com/unicoooorn/pingr/internal/config"
+	"github.com/unicoooorn/pingr/internal/incident"
 	"github.com/unicoooorn/pingr/internal/model"
 	"golang.org/x/sync/errgroup"
 )
@@ -19,13 +21,24 @@ type Service interface {
 	InitiateCheck(ctx context.Context) error
 }
 
+// Components are the parts of the service built from the config. Reload
+// replaces all of them at once.
+type Components struct {
+	Checker              Checker
+	AlertSender          AlertSender
+	AlertGenerator       AlertGenerator
+	MetricsExtractor     MetricsExtractor
+	LogExtractor         LogExtractor
+	InfographicsRenderer InfographicsRenderer
+	Config               config.Config
+}
+
 type serviceImpl struct {
-	checker              Checker
-	alertSender          AlertSender
-	alertGenerator       AlertGenerator
-	metricsExtractor     MetricsExtractor
-	infographicsRenderer InfographicsRenderer
-	cfg                  config.Config
+	history    *checkHistory
+	components atomic.Pointer[Components]
+	// alerting занят, пока идёт алерт предыдущей проверки
+	alerting atomic.Bool
+	alerts   sync.WaitGroup
 }
 
 func New(
@@ -33,17 +46,31 @@ func New(
 	alertSender AlertSender,
 	alertGenerator AlertGenerator,
 	metricsExtractor MetricsExtractor,
+	logExtractor LogExtractor,
 	infographicsRenderer InfographicsRenderer,
 	cfg config.Config,
 ) *serviceImpl {
-	return &serviceImpl{
-		checker:              checker,
-		alertSender:          alertSender,
-		alertGenerator:       alertGenerator,
-		metricsExtractor:     metricsExtractor,
-		infographicsRenderer: infographicsRenderer,
-		cfg:                  cfg,
+	s := &serviceImpl{
+		history: newCheckHistory(cfg.History.Size),
 	}
+	s.components.Store(&Components{
+		Checker:              checker,
+		AlertSender:          alertSender,
+		AlertGenerator:       alertGenerator,
+		MetricsExtractor:     metricsExtractor,
+		LogExtractor:         logExtractor,
+		InfographicsRenderer: infographicsRenderer,
+		Config:               cfg,
+	})
+	return s
+}
+
+// Reload подменяет компоненты для следующих проверок. Идущая проверка
+// доработает со старыми. История бэкендов, оставшихся в конфиге, сохраняется.
+func (s *serviceImpl) Reload(c Components) {
+	s.history.resize(c.Config.History.Size)
+	s.history.retain(c.Config.Backends)
+	s.components.Store(&c)
 }
 
 func (s *serviceImpl) GetStatus(ctx context.Context, subsystem string) (model.CheckResult, error) {
@@ -54,7 +81,9 @@ func (s *serviceImpl) GetStatus(ctx context.Context, subsystem string) (model.Ch
 }
 
 func (s *serviceImpl) InitiateCheck(ctx context.Context) error {
-	statuses, err := s.check(ctx)
+	c := s.components.Load()
+
+	statuses, err := s.check(ctx, c)
 	if err != nil {
 		return fmt.Errorf("check stage: %w", err)
 	}
@@ -72,27 +101,52 @@ func (s *serviceImpl) InitiateCheck(ctx context.Context) error {
 
 	slog.Info("encounter unhealthy state")
 
-	if err := s.alert(ctx, statuses); err != nil {
-		return fmt.Errorf("alert stage: %w", err)
-	}
+	s.startAlert(ctx, c, statuses)
 
 	return nil
 }
 
-func (s *serviceImpl) check(ctx context.Context) (map[string]model.CheckResult, error) {
+// startAlert отправляет алерт в фоне: зависшая LLM или медленный Telegram не
+// должны останавливать проверки. Пока предыдущий алерт не отправлен, новый не
+// начинается, его заменит алерт следующей проверки.
+func (s *serviceImpl) startAlert(ctx context.Context, c *Components, statuses map[string]model.CheckResult) {
+	if !s.alerting.CompareAndSwap(false, true) {
+		slog.Warn("previous alert is still in progress, skipping alert")
+		alertsSkipped.Inc()
+		return
+	}
+
+	s.alerts.Add(1)
+	go func() {
+		defer s.alerts.Done()
+		defer s.alerting.Store(false)
+
+		if err := s.alert(ctx, c, statuses); err != nil {
+			slog.Error("alert stage failed", "error", err)
+		}
+	}()
+}
+
+// Wait ждёт, пока отправится алерт, начатый последней проверкой.
+func (s *serviceImpl) Wait() {
+	s.alerts.Wait()
+}
+
+func (s *serviceImpl) check(ctx context.Context, c *Components) (map[string]model.CheckResult, error) {
 	eg, ectx := errgroup.WithContext(ctx)
 	eg.SetLimit(10)
 
 	var mu sync.Mutex
 	statuses := make(map[string]model.CheckResult)
 
-	for backend := range s.cfg.Backends {
+	for backend := range c.Config.Backends {
 		eg.Go(
 			func() error {
-				res, err := s.checker.Check(ectx, backend)
+				res, err := c.Checker.Check(ectx, backend)
 				if err != nil {
 					return fmt.Errorf("check health of %s: %w", backend, err)
 				}
+				s.history.record(backend, res)
 
 				mu.Lock()
 				statuses[backend] = res
@@ -110,39 +164,26 @@ func (s *serviceImpl) check(ctx context.Context) (map[string]model.CheckResult,
 	return statuses, nil
 }
 
-func (s *serviceImpl) alert(ctx context.Context, statuses map[string]model.CheckResult) error {
-	subsystemInfoByName := make(map[string]model.SubsystemInfo)
-
-	for backend, status := range statuses {
-		metricsRes, err := s.metricsExtractor.Extract(
-			ctx,
-			backend,
-			s.cfg.Backends[backend].MetricsQueries,
-		)
-		if err != nil {
-			return fmt.Errorf("extarct metrics: %w", err)
-		}
-		subsystemInfoByName[backend] = model.SubsystemInfo{
-			Check:  status,
-			Metric: metricsRes,
-		}
+func (s *serviceImpl) alert(ctx context.Context, c *Components, statuses map[string]model.CheckResult) error {
+	subsystemInfoByName, err := s.extract(ctx, c, statuses)
+	if err != nil {
+		return err
 	}
 
-	msg, err := s.alertGenerator.GenerateAlertMessage(
+	msg, err := c.AlertGenerator.GenerateAlertMessage(
 		ctx, subsystemInfoByName,
 	)
 	if err != nil {
 		return fmt.Errorf("generate alert msg: %w", err)
 	}
+	markSuspectedRootCause(subsystemInfoByName, msg.Analysis)
+	s.history.annotate(incident.Failing(subsystemInfoByName), msg.Analysis)
 
-	infographic, err := s.infographicsRenderer.Render(ctx, subsystemInfoByName)
-	if err != nil {
-		return fmt.Errorf("render infographics: %w", err)
-	}
+	infographic := renderInfographics(ctx, c, subsystemInfoByName)
 
-	if err := s.alertSender.SendAlert(
+	if err := c.AlertSender.SendAlert(
 		ctx,
-		msg,
+		msg.Text,
 		infographic,
 	); err != nil {
 		return fmt.Errorf("send alert: %w", err)
@@ -150,3 +191,95 @@ func (s *serviceImpl) alert(ctx context.Context, statuses map[string]model.Check
 
 	return nil
 }
+
+func (s *serviceImpl) extract(ctx context.Context, c *Components, statuses map[string]model.CheckResult) (map[string]model.SubsystemInfo, error) {
+	eg, ectx := errgroup.WithContext(ctx)
+	eg.SetLimit(10)
+
+	var mu sync.Mutex
+	subsystemInfoByName := make(map[string]model.SubsystemInfo)
+
+	for backend, status := range statuses {
+		eg.Go(
+			func() error {
+				metricsRes, err := c.MetricsExtractor.Extract(
+					ectx,
+					backend,
+					c.Config.MetricsQueriesFor(backend),
+				)
+				if err != nil {
+					return fmt.Errorf("extarct metrics: %w", err)
+				}
+
+				var logsRes model.LogExtractorResult
+				if status.Status == model.PingStatusNotOk {
+					logsRes = extractLogs(ectx, c, backend)
+				}
+
+				mu.Lock()
+				subsystemInfoByName[backend] = model.SubsystemInfo{
+					Check:    status,
+					Metric:   metricsRes,
+					Logs:     logsRes,
+					Timeline: s.history.timeline(backend),
+				}
+				mu.Unlock()
+
+				return nil
+			},
+		)
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return subsystemInfoByName, nil
+}
+
+// renderInfographics рисует картинку к алерту. Без картинки алерт уходит
+// текстом, поэтому ошибка рендера только логируется и считается.
+func renderInfographics(ctx context.Context, c *Components, subsystemInfoByName map[string]model.SubsystemInfo) []byte {
+	if c.InfographicsRenderer == nil {
+		return nil
+	}
+
+	// Telegram принимает только растровые картинки
+	infographic, err := c.InfographicsRenderer.Render(ctx, subsystemInfoByName, model.InfographicFormatPNG)
+	if err != nil {
+		slog.Warn("render infographics, sending text-only alert", "error", err)
+		renderFailures.Inc()
+		return nil
+	}
+	return infographic.Data
+}
+
+// markSuspectedRootCause отмечает бэкенд, названный в RCA первопричиной,
+// чтобы инфографика могла его выделить.
+func markSuspectedRootCause(subsystemInfoByName map[string]model.SubsystemInfo, analysis *model.RCAAnalysis) {
+	if analysis == nil {
+		return
+	}
+	info, ok := subsystemInfoByName[analysis.RootCauseBackend]
+	if !ok {
+		return
+	}
+	info.SuspectedRootCause = true
+	subsystemInfoByName[analysis.RootCauseBackend] = info
+}
+
+// extractLogs достаёт последние строки логов упавшего бэкенда.
+// Логи дополняют алерт, поэтому ошибка Loki не должна его блокировать.
+func extractLogs(ctx context.Context, c *Components, backend string) model.LogExtractorResult {
+	query := c.Config.Backends[backend].LogQuery
+	if c.LogExtractor == nil || query == "" {
+		return model.LogExtractorResult{}
+	}
+
+	res, err := c.LogExtractor.Extract(ctx, backend, query)
+	if err != nil {
+		slog.Warn("extract logs", "backend", backend, "error", err)
+		return model.LogExtractorResult{Details: fmt.Sprintf("failed to extract logs: %v", err)}
+	}
+	return res
+}
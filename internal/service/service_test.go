@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/unicoooorn/pingr/internal/config"
 	"github.com/unicoooorn/pingr/internal/model"
 	"github.com/unicoooorn/pingr/internal/service"
@@ -14,7 +16,7 @@ import (
 )
 
 func TestKakDela(t *testing.T) {
-	svc := service.New(nil, nil, nil, nil, nil, config.Config{})
+	svc := service.New(nil, nil, nil, nil, nil, nil, config.Config{})
 
 	res, err := svc.GetStatus(context.Background(), "kak dela")
 
@@ -44,6 +46,7 @@ func TestInitiateCheck_AllHealthy_NoAlertSent(t *testing.T) {
 		alertSender,
 		alertGenerator,
 		metricsExtractor,
+		nil,
 		infographicsRenderer,
 		cfg,
 	)
@@ -59,6 +62,7 @@ func TestInitiateCheck_AllHealthy_NoAlertSent(t *testing.T) {
 
 	// Выполняем проверку
 	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
 
 	// Проверяем что нет ошибок и все моки вызваны как ожидалось
 	assert.NoError(t, err)
@@ -88,6 +92,7 @@ func TestInitiateCheck_UnhealthySystem_AlertSent(t *testing.T) {
 		alertSender,
 		alertGenerator,
 		metricsExtractor,
+		nil,
 		infographicsRenderer,
 		cfg,
 	)
@@ -99,18 +104,18 @@ func TestInitiateCheck_UnhealthySystem_AlertSent(t *testing.T) {
 		Return(model.CheckResult{Status: model.PingStatusOk}, nil).Once()
 
 	// Метрики для обоих бэкендов
-	metricsExtractor.On("Extract", mock.Anything, "backend1", []string(nil)).
+	metricsExtractor.On("Extract", mock.Anything, "backend1", []config.MetricsQuery(nil)).
 		Return(model.MetricsExtractorResult{}, nil).Once()
-	metricsExtractor.On("Extract", mock.Anything, "backend2", []string(nil)).
+	metricsExtractor.On("Extract", mock.Anything, "backend2", []config.MetricsQuery(nil)).
 		Return(model.MetricsExtractorResult{}, nil).Once()
 
 	// Генерация сообщения алерта
 	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.AnythingOfType("map[string]model.SubsystemInfo")).
-		Return("Test alert message", nil).Once()
+		Return(model.AlertMessage{Text: "Test alert message"}, nil).Once()
 
 	// Рендер инфографики
-	infographicsRenderer.On("Render", mock.Anything, mock.AnythingOfType("map[string]model.SubsystemInfo")).
-		Return([]byte("infographic"), nil).Once()
+	infographicsRenderer.On("Render", mock.Anything, mock.AnythingOfType("map[string]model.SubsystemInfo"), model.InfographicFormatPNG).
+		Return(model.Infographic{Data: []byte("infographic"), ContentType: "image/png"}, nil).Once()
 
 	// Отправка алерта
 	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte("infographic")).
@@ -118,6 +123,7 @@ func TestInitiateCheck_UnhealthySystem_AlertSent(t *testing.T) {
 
 	// Выполняем проверку
 	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
 
 	// Проверяем что нет ошибок и все моки вызваны как ожидалось
 	assert.NoError(t, err)
@@ -128,6 +134,58 @@ func TestInitiateCheck_UnhealthySystem_AlertSent(t *testing.T) {
 	alertSender.AssertExpectations(t)
 }
 
+// Зависшая LLM не останавливает проверки: следующая проверка идёт сразу,
+// а её алерт пропускается, пока не отправлен предыдущий
+func TestInitiateCheck_HungLLMDoesNotBlockChecks(t *testing.T) {
+	checker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{"backend1": {}},
+	}
+
+	srv := service.New(checker, alertSender, alertGenerator, metricsExtractor, nil, nil, cfg)
+
+	checker.On("Check", mock.Anything, "backend1").
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Times(3)
+	metricsExtractor.On("Extract", mock.Anything, "backend1", []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Once()
+
+	generating := make(chan struct{})
+	release := make(chan struct{})
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(generating)
+			<-release
+		}).
+		Return(model.AlertMessage{Text: "Test alert message"}, nil).Once()
+	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte(nil)).
+		Return(nil).Once()
+
+	require.NoError(t, srv.InitiateCheck(context.Background()))
+	<-generating
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, srv.InitiateCheck(context.Background()))
+		assert.NoError(t, srv.InitiateCheck(context.Background()))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("checks blocked by a hung alert generator")
+	}
+
+	close(release)
+	srv.Wait()
+	checker.AssertExpectations(t)
+	alertGenerator.AssertExpectations(t)
+	alertSender.AssertExpectations(t)
+}
+
 // Тест когда проверка возвращает ошибку
 func TestInitiateCheck_CheckFails_ReturnsError(t *testing.T) {
 	checker := &mocks.MockChecker{}
@@ -145,6 +203,7 @@ func TestInitiateCheck_CheckFails_ReturnsError(t *testing.T) {
 		alertSender,
 		&mocks.MockAlertGenerator{},
 		&mocks.MockMetricsExtractor{},
+		&mocks.MockLogExtractor{},
 		&mocks.MockInfographicsRenderer{},
 		cfg,
 	)
@@ -155,6 +214,7 @@ func TestInitiateCheck_CheckFails_ReturnsError(t *testing.T) {
 		Return(model.CheckResult{}, expectedErr).Once()
 
 	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
 
 	// Должны получить ошибку
 	assert.Error(t, err)
@@ -170,6 +230,7 @@ func TestGetStatus_SpecialCase(t *testing.T) {
 		&mocks.MockAlertSender{},
 		&mocks.MockAlertGenerator{},
 		&mocks.MockMetricsExtractor{},
+		&mocks.MockLogExtractor{},
 		&mocks.MockInfographicsRenderer{},
 		config.Config{},
 	)
@@ -179,3 +240,317 @@ func TestGetStatus_SpecialCase(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, model.CheckResult{Status: "ok", Details: ""}, result)
 }
+
+// Логи запрашиваются только для упавших бэкендов и ошибка Loki не блокирует алерт
+func TestInitiateCheck_LogsExtractedForFailingBackends(t *testing.T) {
+	checker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+	logExtractor := &mocks.MockLogExtractor{}
+	infographicsRenderer := &mocks.MockInfographicsRenderer{}
+
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"backend1": {LogQuery: `{job="backend1"}`},
+			"backend2": {LogQuery: `{job="backend2"}`},
+			"backend3": {LogQuery: `{job="backend3"}`},
+		},
+	}
+
+	srv := service.New(
+		checker,
+		alertSender,
+		alertGenerator,
+		metricsExtractor,
+		logExtractor,
+		infographicsRenderer,
+		cfg,
+	)
+
+	checker.On("Check", mock.Anything, "backend1").
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Once()
+	checker.On("Check", mock.Anything, "backend2").
+		Return(model.CheckResult{Status: model.PingStatusOk}, nil).Once()
+	checker.On("Check", mock.Anything, "backend3").
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Once()
+
+	metricsExtractor.On("Extract", mock.Anything, mock.Anything, []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Times(3)
+
+	logs := model.LogExtractorResult{Lines: []model.LogLine{{Line: "error: boom"}}}
+	logExtractor.On("Extract", mock.Anything, "backend1", `{job="backend1"}`).
+		Return(logs, nil).Once()
+	logExtractor.On("Extract", mock.Anything, "backend3", `{job="backend3"}`).
+		Return(model.LogExtractorResult{}, errors.New("loki down")).Once()
+
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.MatchedBy(func(infos map[string]model.SubsystemInfo) bool {
+		return assert.Equal(t, logs, infos["backend1"].Logs) &&
+			assert.Empty(t, infos["backend2"].Logs.Lines) &&
+			assert.Contains(t, infos["backend3"].Logs.Details, "loki down")
+	})).Return(model.AlertMessage{Text: "Test alert message"}, nil).Once()
+
+	infographicsRenderer.On("Render", mock.Anything, mock.Anything, model.InfographicFormatPNG).
+		Return(model.Infographic{Data: []byte("infographic"), ContentType: "image/png"}, nil).Once()
+	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte("infographic")).
+		Return(nil).Once()
+
+	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
+
+	assert.NoError(t, err)
+	logExtractor.AssertExpectations(t)
+	alertGenerator.AssertExpectations(t)
+	alertSender.AssertExpectations(t)
+}
+
+// Первопричина из RCA передаётся в инфографику
+func TestInitiateCheck_SuspectedRootCauseReachesRenderer(t *testing.T) {
+	checker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+	infographicsRenderer := &mocks.MockInfographicsRenderer{}
+
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"backend1": {},
+			"backend2": {Deps: []string{"backend1"}},
+		},
+	}
+
+	srv := service.New(
+		checker,
+		alertSender,
+		alertGenerator,
+		metricsExtractor,
+		nil,
+		infographicsRenderer,
+		cfg,
+	)
+
+	checker.On("Check", mock.Anything, mock.Anything).
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Times(2)
+	metricsExtractor.On("Extract", mock.Anything, mock.Anything, []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Times(2)
+
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.Anything).
+		Return(model.AlertMessage{
+			Text:     "Test alert message",
+			Analysis: &model.RCAAnalysis{RootCauseBackend: "backend1", Confidence: 0.9},
+		}, nil).Once()
+
+	infographicsRenderer.On("Render", mock.Anything, mock.MatchedBy(func(infos map[string]model.SubsystemInfo) bool {
+		return infos["backend1"].SuspectedRootCause && !infos["backend2"].SuspectedRootCause
+	}), model.InfographicFormatPNG).Return(model.Infographic{Data: []byte("infographic"), ContentType: "image/png"}, nil).Once()
+	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte("infographic")).
+		Return(nil).Once()
+
+	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
+
+	assert.NoError(t, err)
+	infographicsRenderer.AssertExpectations(t)
+	alertSender.AssertExpectations(t)
+}
+
+// В алерт попадает история смены статусов между проверками
+func TestInitiateCheck_TimelineReachesGenerator(t *testing.T) {
+	checker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+	infographicsRenderer := &mocks.MockInfographicsRenderer{}
+
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"backend1": {},
+		},
+	}
+
+	srv := service.New(
+		checker,
+		alertSender,
+		alertGenerator,
+		metricsExtractor,
+		nil,
+		infographicsRenderer,
+		cfg,
+	)
+
+	checker.On("Check", mock.Anything, "backend1").
+		Return(model.CheckResult{Status: model.PingStatusOk}, nil).Once()
+	assert.NoError(t, srv.InitiateCheck(context.Background()))
+	srv.Wait()
+
+	checker.On("Check", mock.Anything, "backend1").
+		Return(model.CheckResult{Status: model.PingStatusNotOk, Details: "timeout"}, nil).Once()
+	metricsExtractor.On("Extract", mock.Anything, "backend1", []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Once()
+
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.MatchedBy(func(infos map[string]model.SubsystemInfo) bool {
+		timeline := infos["backend1"].Timeline
+		return len(timeline) == 2 &&
+			timeline[0].To == model.PingStatusOk &&
+			timeline[1].From == model.PingStatusOk &&
+			timeline[1].To == model.PingStatusNotOk &&
+			timeline[1].Details == "timeout"
+	})).Return(model.AlertMessage{Text: "Test alert message"}, nil).Once()
+	infographicsRenderer.On("Render", mock.Anything, mock.Anything, model.InfographicFormatPNG).
+		Return(model.Infographic{Data: []byte("infographic"), ContentType: "image/png"}, nil).Once()
+	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte("infographic")).
+		Return(nil).Once()
+
+	assert.NoError(t, srv.InitiateCheck(context.Background()))
+	srv.Wait()
+	alertGenerator.AssertExpectations(t)
+}
+
+func TestInitiateCheck_RenderFails_AlertSentWithoutImage(t *testing.T) {
+	checker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+	infographicsRenderer := &mocks.MockInfographicsRenderer{}
+
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"backend1": {},
+		},
+	}
+
+	srv := service.New(
+		checker,
+		alertSender,
+		alertGenerator,
+		metricsExtractor,
+		nil,
+		infographicsRenderer,
+		cfg,
+	)
+
+	checker.On("Check", mock.Anything, "backend1").
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Once()
+	metricsExtractor.On("Extract", mock.Anything, "backend1", []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Once()
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.Anything).
+		Return(model.AlertMessage{Text: "Test alert message"}, nil).Once()
+
+	// Ошибка рендера не должна мешать отправке текста
+	infographicsRenderer.On("Render", mock.Anything, mock.Anything, model.InfographicFormatPNG).
+		Return(model.Infographic{}, errors.New("dot: not found")).Once()
+	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte(nil)).
+		Return(nil).Once()
+
+	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
+
+	assert.NoError(t, err)
+	infographicsRenderer.AssertExpectations(t)
+	alertSender.AssertExpectations(t)
+}
+
+func TestInitiateCheck_InfographicsDisabled_AlertSentWithoutImage(t *testing.T) {
+	checker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+
+	cfg := config.Config{
+		Backends: map[string]config.BackendConfig{
+			"backend1": {},
+		},
+	}
+
+	// Без рендера (infographics.disabled) алерт уходит только текстом
+	srv := service.New(
+		checker,
+		alertSender,
+		alertGenerator,
+		metricsExtractor,
+		nil,
+		nil,
+		cfg,
+	)
+
+	checker.On("Check", mock.Anything, "backend1").
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Once()
+	metricsExtractor.On("Extract", mock.Anything, "backend1", []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Once()
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.Anything).
+		Return(model.AlertMessage{Text: "Test alert message"}, nil).Once()
+	alertSender.On("SendAlert", mock.Anything, "Test alert message", []byte(nil)).
+		Return(nil).Once()
+
+	err := srv.InitiateCheck(context.Background())
+	srv.Wait()
+
+	assert.NoError(t, err)
+	alertSender.AssertExpectations(t)
+}
+
+// Тест перезагрузки конфига: новые компоненты применяются к следующей проверке,
+// история оставшихся бэкендов сохраняется
+func TestReload_SwapsComponentsAndKeepsHistory(t *testing.T) {
+	oldChecker := &mocks.MockChecker{}
+	alertSender := &mocks.MockAlertSender{}
+
+	srv := service.New(
+		oldChecker,
+		alertSender,
+		nil,
+		nil,
+		nil,
+		nil,
+		config.Config{
+			Backends: map[string]config.BackendConfig{
+				"api":    {},
+				"legacy": {},
+			},
+		},
+	)
+
+	oldChecker.On("Check", mock.Anything, "api").
+		Return(model.CheckResult{Status: model.PingStatusOk}, nil).Once()
+	oldChecker.On("Check", mock.Anything, "legacy").
+		Return(model.CheckResult{Status: model.PingStatusOk}, nil).Once()
+	assert.NoError(t, srv.InitiateCheck(context.Background()))
+	srv.Wait()
+
+	newChecker := &mocks.MockChecker{}
+	metricsExtractor := &mocks.MockMetricsExtractor{}
+	alertGenerator := &mocks.MockAlertGenerator{}
+	srv.Reload(service.Components{
+		Checker:          newChecker,
+		AlertSender:      alertSender,
+		AlertGenerator:   alertGenerator,
+		MetricsExtractor: metricsExtractor,
+		Config: config.Config{
+			Backends: map[string]config.BackendConfig{
+				"api":   {},
+				"cache": {},
+			},
+		},
+	})
+
+	newChecker.On("Check", mock.Anything, "api").
+		Return(model.CheckResult{Status: model.PingStatusNotOk}, nil).Once()
+	newChecker.On("Check", mock.Anything, "cache").
+		Return(model.CheckResult{Status: model.PingStatusOk}, nil).Once()
+	metricsExtractor.On("Extract", mock.Anything, mock.Anything, []config.MetricsQuery(nil)).
+		Return(model.MetricsExtractorResult{}, nil).Twice()
+	alertGenerator.On("GenerateAlertMessage", mock.Anything, mock.MatchedBy(func(infos map[string]model.SubsystemInfo) bool {
+		_, hasLegacy := infos["legacy"]
+		return !hasLegacy && len(infos["api"].Timeline) == 2 && len(infos["cache"].Timeline) == 1
+	})).Return(model.AlertMessage{Text: "api is down"}, nil).Once()
+	alertSender.On("SendAlert", mock.Anything, "api is down", []byte(nil)).
+		Return(nil).Once()
+
+	assert.NoError(t, srv.InitiateCheck(context.Background()))
+	srv.Wait()
+	oldChecker.AssertExpectations(t)
+	newChecker.AssertExpectations(t)
+	metricsExtractor.AssertExpectations(t)
+	alertGenerator.AssertExpectations(t)
+	alertSender.AssertExpectations(t)
+}